@@ -0,0 +1,149 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package argo converts Argo Workflows `Workflow`/`WorkflowTemplate`
+// manifests into the KFP internal pipeline spec.
+package argo
+
+import (
+	"strings"
+
+	"github.com/kubeflow/pipelines/backend/src/apiserver/converter"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	apiVersionPrefix = "argoproj.io/"
+	kindWorkflow     = "Workflow"
+	kindTemplate     = "WorkflowTemplate"
+)
+
+func init() {
+	converter.Register(&argoConverter{})
+}
+
+type argoConverter struct{}
+
+func (c *argoConverter) Format() converter.SourceFormat {
+	return converter.SourceFormatArgo
+}
+
+func (c *argoConverter) Matches(manifest []byte) bool {
+	doc, err := converter.FindDocument(manifest, kindWorkflow, kindTemplate)
+	if err != nil || doc == nil {
+		return false
+	}
+	return strings.HasPrefix(doc.APIVersion, apiVersionPrefix)
+}
+
+// workflow is the minimal subset of the Argo Workflow spec this converter
+// reads in order to produce a KFP pipeline spec.
+type workflow struct {
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Entrypoint string `yaml:"entrypoint"`
+		Templates  []struct {
+			Name      string `yaml:"name"`
+			Container struct {
+				Image   string   `yaml:"image"`
+				Command []string `yaml:"command"`
+				Args    []string `yaml:"args"`
+			} `yaml:"container"`
+			DAG struct {
+				Tasks []struct {
+					Name         string   `yaml:"name"`
+					Template     string   `yaml:"template"`
+					Dependencies []string `yaml:"dependencies"`
+				} `yaml:"tasks"`
+			} `yaml:"dag"`
+		} `yaml:"templates"`
+	} `yaml:"spec"`
+}
+
+// Convert translates an Argo Workflow's DAG templates into KFP DAG tasks,
+// mapping each Argo container template onto a KFP component that runs that
+// same container image/command/args. A task whose template is unknown or has
+// no container (e.g. `script` and `resource` templates) is skipped and
+// reported back as a warning rather than failing the conversion. Other Argo
+// fields (e.g. `withItems` loops, sidecars) are not read, and are dropped
+// without a warning.
+func (c *argoConverter) Convert(manifest []byte) ([]byte, []string, error) {
+	doc, err := converter.FindDocument(manifest, kindWorkflow, kindTemplate)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to scan Argo manifest")
+	}
+	if doc == nil {
+		return nil, nil, errors.New("manifest contains no Argo Workflow/WorkflowTemplate document")
+	}
+
+	var wf workflow
+	if err := yaml.Unmarshal(doc.Raw, &wf); err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to parse Argo Workflow manifest")
+	}
+	if wf.Spec.Entrypoint == "" {
+		return nil, nil, errors.New("Argo Workflow manifest has no spec.entrypoint")
+	}
+
+	var warnings []string
+	templatesByName := make(map[string]int, len(wf.Spec.Templates))
+	for i, t := range wf.Spec.Templates {
+		templatesByName[t.Name] = i
+	}
+
+	spec := converter.KFPPipelineSpec{
+		PipelineInfo: converter.KFPPipelineInfo{Name: wf.Metadata.Name},
+		Root:         converter.KFPComponentSpec{Dag: &converter.KFPDagSpec{Tasks: map[string]converter.KFPTaskSpec{}}},
+		Components:   map[string]converter.KFPComponentSpec{},
+		DeploymentSpec: converter.KFPDeploymentSpec{
+			Executors: map[string]converter.KFPExecutorSpec{},
+		},
+	}
+	entrypoint, ok := templatesByName[wf.Spec.Entrypoint]
+	if !ok {
+		return nil, nil, errors.Errorf("Argo Workflow entrypoint template %q not found", wf.Spec.Entrypoint)
+	}
+	for _, task := range wf.Spec.Templates[entrypoint].DAG.Tasks {
+		idx, ok := templatesByName[task.Template]
+		if !ok {
+			warnings = append(warnings, "task \""+task.Name+"\" references unknown template \""+task.Template+"\", skipped")
+			continue
+		}
+		tmpl := wf.Spec.Templates[idx]
+		if tmpl.Container.Image == "" {
+			warnings = append(warnings, "template \""+task.Template+"\" has no container spec (script/resource templates are not yet supported), skipped")
+			continue
+		}
+		spec.Root.Dag.Tasks[task.Name] = converter.KFPTaskSpec{
+			ComponentRef:   converter.KFPComponentRef{Name: task.Template},
+			DependentTasks: task.Dependencies,
+		}
+		spec.Components[task.Template] = converter.KFPComponentSpec{}
+		spec.DeploymentSpec.Executors[task.Template] = converter.KFPExecutorSpec{
+			Container: converter.KFPContainerSpec{
+				Image:   tmpl.Container.Image,
+				Command: tmpl.Container.Command,
+				Args:    tmpl.Container.Args,
+			},
+		}
+	}
+
+	out, err := yaml.Marshal(spec)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to marshal converted KFP pipeline spec")
+	}
+	return out, warnings, nil
+}
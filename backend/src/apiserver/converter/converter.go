@@ -0,0 +1,247 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package converter detects the authoring format of an uploaded pipeline
+// manifest and converts non-native formats into the KFP internal pipeline
+// spec, so they can be persisted and run the same way as a compiled KFP
+// pipeline.
+package converter
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// SourceFormat identifies which tool authored an uploaded pipeline manifest.
+type SourceFormat string
+
+const (
+	// SourceFormatAuto asks DetectFormat to infer the format from the manifest
+	// contents.
+	SourceFormatAuto SourceFormat = "auto"
+	// SourceFormatKFP is KFP's own IR/YAML pipeline spec; it requires no
+	// conversion.
+	SourceFormatKFP SourceFormat = "kfp"
+	// SourceFormatArgo is an Argo Workflows `Workflow` or `WorkflowTemplate`
+	// manifest.
+	SourceFormatArgo SourceFormat = "argo"
+	// SourceFormatTekton is a Tekton `Pipeline` or `PipelineRun` manifest.
+	SourceFormatTekton SourceFormat = "tekton"
+)
+
+// typeMeta is the subset of a Kubernetes manifest's apiVersion/kind fields
+// needed to tell KFP, Argo and Tekton manifests apart.
+type typeMeta struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+}
+
+// ConversionResult is the outcome of converting a non-native manifest into the
+// KFP internal pipeline spec.
+type ConversionResult struct {
+	// DetectedFormat is the format DetectFormat/Convert determined the manifest
+	// to be in.
+	DetectedFormat SourceFormat
+	// PipelineSpec is the converted KFP internal pipeline spec. For
+	// SourceFormatKFP this is identical to the original manifest.
+	PipelineSpec []byte
+	// OriginalManifest is the manifest bytes exactly as uploaded, kept for
+	// round-trip fidelity.
+	OriginalManifest []byte
+	// Warnings are non-fatal issues encountered while converting, surfaced to
+	// the caller instead of failing the upload.
+	Warnings []string
+}
+
+// Converter turns a non-KFP pipeline manifest into the KFP internal pipeline
+// spec. One Converter is registered per non-native SourceFormat.
+type Converter interface {
+	// Format is the SourceFormat this Converter handles.
+	Format() SourceFormat
+	// Matches reports whether manifest looks like this Converter's format,
+	// used by DetectFormat when sourceFormat is SourceFormatAuto.
+	Matches(manifest []byte) bool
+	// Convert converts manifest into a KFP internal pipeline spec, returning
+	// any non-fatal warnings encountered along the way.
+	Convert(manifest []byte) (pipelineSpec []byte, warnings []string, err error)
+}
+
+var registeredConverters []Converter
+
+// Register adds c to the set of converters consulted by DetectFormat and
+// Convert. Called from the init() of each format's sub-package (argo, tekton).
+func Register(c Converter) {
+	registeredConverters = append(registeredConverters, c)
+}
+
+// DetectFormat determines which format manifest is in. When requested is
+// SourceFormatAuto it tries each registered Converter's Matches, falling back
+// to SourceFormatKFP when none claim it (KFP manifests have no single
+// apiVersion/kind to key off of). When requested is not SourceFormatAuto it is
+// returned unchanged, without inspecting manifest.
+func DetectFormat(requested SourceFormat, manifest []byte) SourceFormat {
+	if requested != SourceFormatAuto {
+		return requested
+	}
+	for _, c := range registeredConverters {
+		if c.Matches(manifest) {
+			return c.Format()
+		}
+	}
+	return SourceFormatKFP
+}
+
+// Convert converts manifest from the given format into a ConversionResult. For
+// SourceFormatKFP this is a no-op copy; for SourceFormatAuto the format is
+// first detected via DetectFormat.
+func Convert(requested SourceFormat, manifest []byte) (*ConversionResult, error) {
+	format := DetectFormat(requested, manifest)
+	if format == SourceFormatKFP {
+		return &ConversionResult{
+			DetectedFormat:   SourceFormatKFP,
+			PipelineSpec:     manifest,
+			OriginalManifest: manifest,
+		}, nil
+	}
+
+	for _, c := range registeredConverters {
+		if c.Format() != format {
+			continue
+		}
+		spec, warnings, err := c.Convert(manifest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to convert %s manifest to a KFP pipeline spec", format)
+		}
+		return &ConversionResult{
+			DetectedFormat:   format,
+			PipelineSpec:     spec,
+			OriginalManifest: manifest,
+			Warnings:         warnings,
+		}, nil
+	}
+	return nil, errors.Errorf("no converter registered for source format %q", format)
+}
+
+// KFPPipelineSpec is a minimal YAML-shaped approximation of the real KFP IR
+// (the pipelineInfo/root/components/deploymentSpec shape that
+// pipeline_spec.proto compiles to), sufficient for a converted manifest to
+// satisfy the same structural checks a natively-compiled KFP pipeline would
+// (see apiserver/pipeline.requiredFieldsRule and friends). Every Converter
+// should emit this shape rather than inventing its own, so converted
+// pipelines are structurally indistinguishable from native ones.
+type KFPPipelineSpec struct {
+	PipelineInfo   KFPPipelineInfo             `yaml:"pipelineInfo"`
+	Root           KFPComponentSpec            `yaml:"root"`
+	Components     map[string]KFPComponentSpec `yaml:"components,omitempty"`
+	DeploymentSpec KFPDeploymentSpec           `yaml:"deploymentSpec"`
+	SchemaVersion  string                      `yaml:"schemaVersion,omitempty"`
+}
+
+// KFPPipelineInfo carries the pipeline's identity.
+type KFPPipelineInfo struct {
+	Name string `yaml:"name"`
+}
+
+// KFPComponentSpec is either a DAG of tasks (the pipeline root, or a
+// sub-pipeline) or a leaf executor reference. This package's converters set a
+// DAG only on the root; each entry they add to Components is empty, and its
+// container lives in DeploymentSpec.Executors under the same name.
+type KFPComponentSpec struct {
+	Dag *KFPDagSpec `yaml:"dag,omitempty"`
+}
+
+// KFPDagSpec is the set of tasks that make up a DAG component, keyed by task
+// name.
+type KFPDagSpec struct {
+	Tasks map[string]KFPTaskSpec `yaml:"tasks"`
+}
+
+// KFPTaskSpec is a single DAG task: which component it runs, and which
+// sibling tasks it depends on.
+type KFPTaskSpec struct {
+	ComponentRef   KFPComponentRef `yaml:"componentRef"`
+	DependentTasks []string        `yaml:"dependentTasks,omitempty"`
+}
+
+// KFPComponentRef names a component defined in KFPPipelineSpec.Components.
+type KFPComponentRef struct {
+	Name string `yaml:"name"`
+}
+
+// KFPDeploymentSpec maps each leaf component to the container that executes
+// it.
+type KFPDeploymentSpec struct {
+	Executors map[string]KFPExecutorSpec `yaml:"executors"`
+}
+
+// KFPExecutorSpec is the container a single leaf component runs.
+type KFPExecutorSpec struct {
+	Container KFPContainerSpec `yaml:"container"`
+}
+
+// KFPContainerSpec is the subset of a KFP executor's container definition
+// this package's converters populate.
+type KFPContainerSpec struct {
+	Image           string   `yaml:"image"`
+	Command         []string `yaml:"command,omitempty"`
+	Args            []string `yaml:"args,omitempty"`
+	ImagePullPolicy string   `yaml:"imagePullPolicy,omitempty"`
+}
+
+// Document is a single YAML document found within a (possibly
+// multi-document) manifest, along with its apiVersion/kind.
+type Document struct {
+	APIVersion string
+	Kind       string
+	// Raw is this document's own bytes, re-marshaled in isolation, so a
+	// Converter can unmarshal just this document into its own
+	// format-specific struct without tripping over sibling documents.
+	Raw []byte
+}
+
+// FindDocument scans manifest for the first document whose kind is in kinds,
+// tolerating multi-document YAML files -- Argo/Tekton manifests are
+// frequently bundled with a ConfigMap or RBAC alongside the workflow, and
+// yaml.Unmarshal on the whole manifest silently decodes only the first
+// document, which may not be the one a Converter is looking for. Returns
+// (nil, nil) if no document in manifest matches.
+func FindDocument(manifest []byte, kinds ...string) (*Document, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if err == io.EOF {
+				return nil, nil
+			}
+			return nil, err
+		}
+		var meta typeMeta
+		if err := node.Decode(&meta); err != nil {
+			continue
+		}
+		for _, kind := range kinds {
+			if meta.Kind != kind {
+				continue
+			}
+			raw, err := yaml.Marshal(&node)
+			if err != nil {
+				return nil, err
+			}
+			return &Document{APIVersion: meta.APIVersion, Kind: meta.Kind, Raw: raw}, nil
+		}
+	}
+}
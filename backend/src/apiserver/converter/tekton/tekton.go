@@ -0,0 +1,149 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tekton converts Tekton `Pipeline`/`PipelineRun` manifests into the
+// KFP internal pipeline spec.
+package tekton
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/kubeflow/pipelines/backend/src/apiserver/converter"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	apiVersionPrefix = "tekton.dev/"
+	kindPipeline     = "Pipeline"
+	kindPipelineRun  = "PipelineRun"
+)
+
+func init() {
+	converter.Register(&tektonConverter{})
+}
+
+type tektonConverter struct{}
+
+func (c *tektonConverter) Format() converter.SourceFormat {
+	return converter.SourceFormatTekton
+}
+
+func (c *tektonConverter) Matches(manifest []byte) bool {
+	doc, err := converter.FindDocument(manifest, kindPipeline, kindPipelineRun)
+	if err != nil || doc == nil {
+		return false
+	}
+	return strings.HasPrefix(doc.APIVersion, apiVersionPrefix)
+}
+
+// tektonPipeline is the minimal subset of the Tekton Pipeline spec this
+// converter reads. A PipelineRun that embeds its pipelineSpec inline is read
+// the same way; a PipelineRun that only references a pipelineRef is rejected
+// with an error, since the referenced Pipeline is not available at upload time.
+type tektonPipeline struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec struct {
+		PipelineRef *struct {
+			Name string `yaml:"name"`
+		} `yaml:"pipelineRef"`
+		PipelineSpec *tektonPipelineSpec `yaml:"pipelineSpec"`
+		Tasks        []tektonTask        `yaml:"tasks"`
+	} `yaml:"spec"`
+}
+
+type tektonPipelineSpec struct {
+	Tasks []tektonTask `yaml:"tasks"`
+}
+
+type tektonTask struct {
+	Name     string   `yaml:"name"`
+	RunAfter []string `yaml:"runAfter"`
+	TaskSpec *struct {
+		Steps []struct {
+			Image   string   `yaml:"image"`
+			Command []string `yaml:"command"`
+			Args    []string `yaml:"args"`
+		} `yaml:"steps"`
+	} `yaml:"taskSpec"`
+}
+
+// Convert translates a Tekton Pipeline's tasks into KFP DAG tasks. Each
+// Tekton task's first step becomes the KFP component's container; additional
+// steps within a task (Tekton's multi-step-per-pod model has no direct KFP
+// equivalent) are reported as warnings.
+func (c *tektonConverter) Convert(manifest []byte) ([]byte, []string, error) {
+	doc, err := converter.FindDocument(manifest, kindPipeline, kindPipelineRun)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to scan Tekton manifest")
+	}
+	if doc == nil {
+		return nil, nil, errors.New("manifest contains no Tekton Pipeline/PipelineRun document")
+	}
+
+	var p tektonPipeline
+	if err := yaml.Unmarshal(doc.Raw, &p); err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to parse Tekton manifest")
+	}
+
+	tasks := p.Spec.Tasks
+	if p.Spec.PipelineSpec != nil {
+		tasks = p.Spec.PipelineSpec.Tasks
+	}
+	var warnings []string
+	if p.Kind == kindPipelineRun && p.Spec.PipelineRef != nil && len(tasks) == 0 {
+		return nil, nil, errors.Errorf("PipelineRun references pipelineRef %q; only inline pipelineSpec can be converted", p.Spec.PipelineRef.Name)
+	}
+
+	spec := converter.KFPPipelineSpec{
+		PipelineInfo: converter.KFPPipelineInfo{Name: p.Metadata.Name},
+		Root:         converter.KFPComponentSpec{Dag: &converter.KFPDagSpec{Tasks: map[string]converter.KFPTaskSpec{}}},
+		Components:   map[string]converter.KFPComponentSpec{},
+		DeploymentSpec: converter.KFPDeploymentSpec{
+			Executors: map[string]converter.KFPExecutorSpec{},
+		},
+	}
+	for _, t := range tasks {
+		if t.TaskSpec == nil || len(t.TaskSpec.Steps) == 0 {
+			warnings = append(warnings, "task \""+t.Name+"\" has no inline taskSpec steps (taskRef is not yet supported), skipped")
+			continue
+		}
+		if len(t.TaskSpec.Steps) > 1 {
+			warnings = append(warnings, "task \""+t.Name+"\" has "+strconv.Itoa(len(t.TaskSpec.Steps))+" steps; only the first step was converted")
+		}
+		step := t.TaskSpec.Steps[0]
+		spec.Root.Dag.Tasks[t.Name] = converter.KFPTaskSpec{
+			ComponentRef:   converter.KFPComponentRef{Name: t.Name},
+			DependentTasks: t.RunAfter,
+		}
+		spec.Components[t.Name] = converter.KFPComponentSpec{}
+		spec.DeploymentSpec.Executors[t.Name] = converter.KFPExecutorSpec{
+			Container: converter.KFPContainerSpec{
+				Image:   step.Image,
+				Command: step.Command,
+				Args:    step.Args,
+			},
+		}
+	}
+
+	out, err := yaml.Marshal(spec)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to marshal converted KFP pipeline spec")
+	}
+	return out, warnings, nil
+}
@@ -0,0 +1,356 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// requiredFieldsRule flags a pipeline spec that is missing the fields every
+// KFP pipeline must have to be runnable.
+type requiredFieldsRule struct{}
+
+func (r *requiredFieldsRule) Code() string { return "required-fields" }
+
+func (r *requiredFieldsRule) Check(doc *Document) []Diagnostic {
+	var diags []Diagnostic
+	if lookupScalar(&doc.Root, "pipelineInfo", "name") == "" {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Code:     r.Code(),
+			Path:     "/pipelineInfo/name",
+			Message:  "Pipeline spec is missing pipelineInfo.name",
+		})
+	}
+	if findMapping(&doc.Root, "root") == nil {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Code:     r.Code(),
+			Path:     "/root",
+			Message:  "Pipeline spec is missing the root component definition",
+		})
+	}
+	return diags
+}
+
+// knownTopLevelFields is the set of fields the KFP pipeline spec schema
+// defines at the document root.
+var knownTopLevelFields = map[string]bool{
+	"pipelineInfo":        true,
+	"root":                true,
+	"components":          true,
+	"deploymentSpec":      true,
+	"schemaVersion":       true,
+	"sdkVersion":          true,
+	"defaultPipelineRoot": true,
+}
+
+// unknownFieldsRule flags a top-level field that is not part of the KFP
+// pipeline spec schema, catching typos and stray fields left behind by
+// hand-edited or incorrectly converted manifests.
+type unknownFieldsRule struct{}
+
+func (r *unknownFieldsRule) Code() string { return "unknown-field" }
+
+func (r *unknownFieldsRule) Check(doc *Document) []Diagnostic {
+	root := &doc.Root
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+	var diags []Diagnostic
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i].Value
+		if knownTopLevelFields[key] {
+			continue
+		}
+		diags = append(diags, Diagnostic{
+			Severity: SeverityWarning,
+			Code:     r.Code(),
+			Path:     "/" + key,
+			Message:  fmt.Sprintf("Unknown top-level field %q is not part of the KFP pipeline spec schema", key),
+		})
+	}
+	return diags
+}
+
+// validParameterTypes is the set of parameterType values the KFP IR
+// recognizes for a pipeline or component input/output parameter.
+var validParameterTypes = map[string]bool{
+	"STRING":            true,
+	"NUMBER_INTEGER":    true,
+	"NUMBER_DOUBLE":     true,
+	"BOOLEAN":           true,
+	"LIST":              true,
+	"STRUCT":            true,
+	"TASK_FINAL_STATUS": true,
+}
+
+// parameterTypeMismatchRule flags a root input parameter whose declared
+// parameterType is not one of the KFP IR's recognized parameter types.
+type parameterTypeMismatchRule struct{}
+
+func (r *parameterTypeMismatchRule) Code() string { return "parameter-type-mismatch" }
+
+func (r *parameterTypeMismatchRule) Check(doc *Document) []Diagnostic {
+	params := findMapping(&doc.Root, "root", "inputDefinitions", "parameters")
+	if params == nil {
+		return nil
+	}
+	var diags []Diagnostic
+	for i := 0; i+1 < len(params.Content); i += 2 {
+		name := params.Content[i].Value
+		param := params.Content[i+1]
+		paramType := lookupScalarNode(param, "parameterType")
+		if paramType == "" || validParameterTypes[paramType] {
+			continue
+		}
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Code:     r.Code(),
+			Path:     "/root/inputDefinitions/parameters/" + name + "/parameterType",
+			Message:  fmt.Sprintf("Parameter %q has unrecognized parameterType %q", name, paramType),
+		})
+	}
+	return diags
+}
+
+// cyclicDAGRefRule detects a task DAG that depends on itself, directly or
+// transitively, which would otherwise hang forever at runtime.
+type cyclicDAGRefRule struct{}
+
+func (r *cyclicDAGRefRule) Code() string { return "cyclic-dag-ref" }
+
+func (r *cyclicDAGRefRule) Check(doc *Document) []Diagnostic {
+	tasks := findMapping(&doc.Root, "root", "dag", "tasks")
+	if tasks == nil {
+		return nil
+	}
+
+	dependencies := make(map[string][]string)
+	for i := 0; i+1 < len(tasks.Content); i += 2 {
+		name := tasks.Content[i].Value
+		task := tasks.Content[i+1]
+		dependencies[name] = scalarList(findNode(task, "dependentTasks"))
+	}
+
+	var diags []Diagnostic
+	visited := make(map[string]int) // 0=unvisited, 1=in-progress, 2=done
+	var visit func(name string, stack []string) []string
+	visit = func(name string, stack []string) []string {
+		switch visited[name] {
+		case 1:
+			return append(stack, name)
+		case 2:
+			return nil
+		}
+		visited[name] = 1
+		for _, dep := range dependencies[name] {
+			if cycle := visit(dep, append(stack, name)); cycle != nil {
+				return cycle
+			}
+		}
+		visited[name] = 2
+		return nil
+	}
+	for name := range dependencies {
+		if visited[name] != 0 {
+			continue
+		}
+		if cycle := visit(name, nil); cycle != nil {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Code:     r.Code(),
+				Path:     "/root/dag/tasks/" + name,
+				Message:  fmt.Sprintf("Cyclic task dependency detected: %v", cycle),
+			})
+		}
+	}
+	return diags
+}
+
+// unresolvedComponentRefRule flags a DAG task whose componentRef does not
+// resolve to an entry in the spec's component/executor registry.
+type unresolvedComponentRefRule struct{}
+
+func (r *unresolvedComponentRefRule) Code() string { return "unresolved-component-ref" }
+
+func (r *unresolvedComponentRefRule) Check(doc *Document) []Diagnostic {
+	tasks := findMapping(&doc.Root, "root", "dag", "tasks")
+	components := findMapping(&doc.Root, "components")
+	if tasks == nil {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	if components != nil {
+		for i := 0; i+1 < len(components.Content); i += 2 {
+			known[components.Content[i].Value] = true
+		}
+	}
+
+	var diags []Diagnostic
+	for i := 0; i+1 < len(tasks.Content); i += 2 {
+		name := tasks.Content[i].Value
+		task := tasks.Content[i+1]
+		ref := lookupScalarNode(task, "componentRef", "name")
+		if ref == "" {
+			continue
+		}
+		if !known[ref] {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Code:     r.Code(),
+				Path:     "/root/dag/tasks/" + name + "/componentRef",
+				Message:  fmt.Sprintf("Task %q references unresolved component %q", name, ref),
+			})
+		}
+	}
+	return diags
+}
+
+// imagePullPolicyRule warns about an explicit "Always" image pull policy
+// combined with an image reference pinned to a digest, which defeats the
+// purpose of pinning (the image can never come from local cache).
+type imagePullPolicyRule struct{}
+
+func (r *imagePullPolicyRule) Code() string { return "image-pull-policy" }
+
+func (r *imagePullPolicyRule) Check(doc *Document) []Diagnostic {
+	components := findMapping(&doc.Root, "deploymentSpec", "executors")
+	if components == nil {
+		return nil
+	}
+	var diags []Diagnostic
+	for i := 0; i+1 < len(components.Content); i += 2 {
+		name := components.Content[i].Value
+		executor := components.Content[i+1]
+		image := lookupScalarNode(executor, "container", "image")
+		policy := lookupScalarNode(executor, "container", "imagePullPolicy")
+		if policy == "Always" && containsDigest(image) {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityWarning,
+				Code:     r.Code(),
+				Path:     "/deploymentSpec/executors/" + name + "/container/imagePullPolicy",
+				Message:  fmt.Sprintf("Executor %q pins image %q by digest but sets imagePullPolicy: Always", name, image),
+			})
+		}
+	}
+	return diags
+}
+
+// namespaceQuotaRule warns about an executor that sets no resource requests
+// at all, since such a task is invisible to namespace-scoped ResourceQuota
+// accounting and can starve quota-respecting neighbors. It cannot evaluate
+// the quota itself: that requires the target namespace's live ResourceQuota,
+// which the caller supplies via Document.Namespace and is out of scope for a
+// pure manifest-level rule.
+type namespaceQuotaRule struct{}
+
+func (r *namespaceQuotaRule) Code() string { return "namespace-quota" }
+
+func (r *namespaceQuotaRule) Check(doc *Document) []Diagnostic {
+	if doc.Namespace == "" {
+		return nil
+	}
+	executors := findMapping(&doc.Root, "deploymentSpec", "executors")
+	if executors == nil {
+		return nil
+	}
+	var diags []Diagnostic
+	for i := 0; i+1 < len(executors.Content); i += 2 {
+		name := executors.Content[i].Value
+		executor := executors.Content[i+1]
+		if findNode(executor, "container", "resources", "requests") == nil {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityWarning,
+				Code:     r.Code(),
+				Path:     "/deploymentSpec/executors/" + name + "/container/resources",
+				Message:  fmt.Sprintf("Executor %q sets no resource requests; it will not be counted against namespace %q's ResourceQuota", name, doc.Namespace),
+			})
+		}
+	}
+	return diags
+}
+
+func containsDigest(image string) bool {
+	for i := 0; i+1 < len(image); i++ {
+		if image[i] == '@' {
+			return true
+		}
+	}
+	return false
+}
+
+// findNode walks path from node, returning the node at the end, or nil.
+func findNode(node *yaml.Node, path ...string) *yaml.Node {
+	for _, key := range path {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		node = next
+	}
+	return node
+}
+
+// findMapping is findNode starting from a Document's root, unwrapping the
+// document node first.
+func findMapping(root *yaml.Node, path ...string) *yaml.Node {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	found := findNode(node, path...)
+	if found == nil || found.Kind != yaml.MappingNode {
+		return nil
+	}
+	return found
+}
+
+// lookupScalarNode is lookupScalar starting from an arbitrary mapping node
+// rather than a Document root.
+func lookupScalarNode(node *yaml.Node, path ...string) string {
+	found := findNode(node, path...)
+	if found == nil || found.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return found.Value
+}
+
+// scalarList reads a YAML sequence of scalars into a []string, or returns nil
+// if node is not a sequence.
+func scalarList(node *yaml.Node) []string {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+	out := make([]string, 0, len(node.Content))
+	for _, item := range node.Content {
+		if item.Kind == yaml.ScalarNode {
+			out = append(out, item.Value)
+		}
+	}
+	return out
+}
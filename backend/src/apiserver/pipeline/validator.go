@@ -0,0 +1,186 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pipeline holds validation logic shared between the real pipeline
+// create path and the dry-run/validate-only upload mode, so the two cannot
+// drift apart.
+package pipeline
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity classifies a single validation Diagnostic.
+type Severity string
+
+const (
+	// SeverityError means the upload would be rejected.
+	SeverityError Severity = "error"
+	// SeverityWarning means the upload would succeed, but something about the
+	// pipeline looks suspect.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a single lint-style finding produced by a Rule.
+type Diagnostic struct {
+	// Severity is how serious this finding is.
+	Severity Severity `json:"severity"`
+	// Code is a stable, machine-readable identifier for the rule that produced
+	// this Diagnostic, e.g. "unresolved-component-ref".
+	Code string `json:"code"`
+	// Path is a JSON-pointer into the validated pipeline spec locating the
+	// finding, e.g. "/root/dag/tasks/train". For a converted Argo/Tekton
+	// upload this is the converted KFP spec, not the original manifest.
+	Path string `json:"path"`
+	// Message is a human-readable description of the finding.
+	Message string `json:"message"`
+}
+
+// ValidationReport is the result of running every registered Rule over a
+// pipeline spec.
+type ValidationReport struct {
+	// SchemaVersion is the detected schema version of the pipeline spec, e.g.
+	// "v2beta1", or "" if it could not be determined.
+	SchemaVersion string `json:"schemaVersion"`
+	// Diagnostics is every finding produced across all rules, in rule
+	// registration order.
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	// WouldSucceed is true when Diagnostics contains no SeverityError entries.
+	WouldSucceed bool `json:"wouldSucceed"`
+}
+
+// Rule inspects a parsed pipeline document and reports any Diagnostics it
+// finds. Rules must not mutate doc.
+type Rule interface {
+	// Code is this rule's stable identifier, used as Diagnostic.Code.
+	Code() string
+	// Check inspects doc and appends any findings it has.
+	Check(doc *Document) []Diagnostic
+}
+
+// Document is the parsed form of a pipeline spec that rules inspect. It is
+// intentionally loosely typed (a generic YAML node tree) so that rules can be
+// added without requiring the full KFP IR proto to be vendored here.
+type Document struct {
+	Raw           []byte
+	Root          yaml.Node
+	SchemaVersion string
+	// Namespace is the namespace the pipeline is being uploaded into, supplied
+	// by the caller so namespace-scoped rules (e.g. resource quotas) can run.
+	// It is not derived from the manifest itself.
+	Namespace string
+}
+
+// ParseDocument parses raw pipeline spec bytes into a Document for rules to
+// inspect.
+func ParseDocument(raw []byte) (*Document, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse pipeline spec as YAML: %w", err)
+	}
+	doc := &Document{Raw: raw, Root: root}
+	doc.SchemaVersion = lookupScalar(&root, "schemaVersion")
+	if doc.SchemaVersion == "" {
+		doc.SchemaVersion = lookupScalar(&root, "pipelineInfo", "schemaVersion")
+	}
+	return doc, nil
+}
+
+// lookupScalar walks a chain of mapping keys from root's document node and
+// returns the scalar value at the end, or "" if any key along the path is
+// missing or not a mapping/scalar.
+func lookupScalar(root *yaml.Node, path ...string) string {
+	node := root
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, key := range path {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return ""
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		node = next
+	}
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// Validator runs a fixed registry of Rules over a pipeline spec. A single
+// Validator instance is shared by the dry-run upload path and the real
+// CreatePipeline/CreatePipelineVersion path, so their behavior cannot drift.
+type Validator struct {
+	rules []Rule
+}
+
+// NewValidator constructs a Validator with the default rule registry: the
+// required-fields, unknown-fields, parameter-type-mismatch, cyclic-dag-ref,
+// unresolved-component-ref, image-pull-policy and namespace-quota rules.
+func NewValidator() *Validator {
+	return &Validator{
+		rules: []Rule{
+			&requiredFieldsRule{},
+			&unknownFieldsRule{},
+			&parameterTypeMismatchRule{},
+			&cyclicDAGRefRule{},
+			&unresolvedComponentRefRule{},
+			&imagePullPolicyRule{},
+			&namespaceQuotaRule{},
+		},
+	}
+}
+
+// Validate parses raw and runs every registered rule over it, returning a
+// ValidationReport. A parse failure is reported as a single error Diagnostic
+// rather than returned as a Go error, since a dry-run caller wants a report
+// either way. namespace may be empty, in which case namespace-scoped rules
+// are skipped.
+func (v *Validator) Validate(raw []byte, namespace string) *ValidationReport {
+	report := &ValidationReport{}
+
+	doc, err := ParseDocument(raw)
+	if err != nil {
+		report.Diagnostics = append(report.Diagnostics, Diagnostic{
+			Severity: SeverityError,
+			Code:     "invalid-yaml",
+			Path:     "",
+			Message:  err.Error(),
+		})
+		return report
+	}
+	doc.Namespace = namespace
+	report.SchemaVersion = doc.SchemaVersion
+
+	for _, rule := range v.rules {
+		report.Diagnostics = append(report.Diagnostics, rule.Check(doc)...)
+	}
+
+	report.WouldSucceed = true
+	for _, d := range report.Diagnostics {
+		if d.Severity == SeverityError {
+			report.WouldSucceed = false
+			break
+		}
+	}
+	return report
+}
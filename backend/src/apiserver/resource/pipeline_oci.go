@@ -0,0 +1,255 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kubeflow/pipelines/backend/src/common/util"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+// KFPIRMediaType is the OCI artifact media type used for a pipeline's
+// compiled IR, both when pulling a pipeline from an OCI registry and when
+// pushing one to it.
+const KFPIRMediaType = "application/vnd.kubeflow.pipeline.v1+yaml"
+
+// OCIAuthResolver looks up registry credentials for a namespace, so a
+// multi-tenant server can resolve a different docker config / pull secret per
+// caller.
+type OCIAuthResolver interface {
+	// Credential returns the auth.Credential to use for registryHost on behalf
+	// of namespace, reading from e.g. the namespace's imagePullSecrets or a
+	// server-wide docker config.
+	Credential(namespace, registryHost string) (auth.Credential, error)
+}
+
+// PipelineOCIOptions configures PipelineOCIClient.
+type PipelineOCIOptions struct {
+	// AllowedRegistries restricts which registry hosts PullPipeline/PushPipeline
+	// will contact. An empty list allows any registry; production deployments
+	// that expose PushPipelineToOCI should set this, since an unset allowlist
+	// lets any caller with push access ship a pipeline to an attacker-chosen
+	// registry.
+	AllowedRegistries []string
+	// AuthResolver resolves per-namespace registry credentials.
+	AuthResolver OCIAuthResolver
+	// CacheDir is where pulled pipeline artifacts are cached on disk, keyed by
+	// manifest digest, to avoid re-pulling an unchanged reference. May be "" to
+	// disable caching.
+	CacheDir string
+}
+
+// PipelineOCIClient pulls pipeline artifacts from, and pushes them to, OCI
+// registries via ORAS, so pipelines can be distributed through the same
+// registries users already run for container images.
+type PipelineOCIClient struct {
+	options *PipelineOCIOptions
+}
+
+// NewPipelineOCIClient constructs a PipelineOCIClient from server flags.
+func NewPipelineOCIClient(options *PipelineOCIOptions) *PipelineOCIClient {
+	return &PipelineOCIClient{options: options}
+}
+
+// registryHost extracts the host portion of an "registry/ns/repo[:tag|@digest]"
+// reference.
+func registryHost(ociRef string) string {
+	if idx := strings.Index(ociRef, "/"); idx >= 0 {
+		return ociRef[:idx]
+	}
+	return ociRef
+}
+
+func (c *PipelineOCIClient) checkAllowed(ociRef string) error {
+	if len(c.options.AllowedRegistries) == 0 {
+		return nil
+	}
+	host := registryHost(ociRef)
+	for _, allowed := range c.options.AllowedRegistries {
+		if host == allowed {
+			return nil
+		}
+	}
+	return util.NewInvalidInputError("Registry %q is not in the allowed OCI registry list.", host)
+}
+
+// remoteRepository resolves ociRef to an ORAS remote.Repository, authenticated
+// on behalf of namespace when an AuthResolver is configured.
+func (c *PipelineOCIClient) remoteRepository(namespace, ociRef string) (*remote.Repository, error) {
+	repo, err := remote.NewRepository(ociRef)
+	if err != nil {
+		return nil, util.Wrap(err, "Invalid OCI reference")
+	}
+	client := &auth.Client{Client: retry.DefaultClient, Cache: auth.NewCache()}
+	if c.options.AuthResolver != nil {
+		host := registryHost(ociRef)
+		cred, err := c.options.AuthResolver.Credential(namespace, host)
+		if err != nil {
+			return nil, util.Wrap(err, "Failed to resolve OCI registry credentials")
+		}
+		client.Credential = auth.StaticCredential(host, cred)
+	}
+	repo.Client = client
+	return repo, nil
+}
+
+func (c *PipelineOCIClient) cachePath(manifestDigest digest.Digest) string {
+	if c.options.CacheDir == "" {
+		return ""
+	}
+	return filepath.Join(c.options.CacheDir, strings.ReplaceAll(manifestDigest.String(), ":", "_")+".yaml")
+}
+
+func (c *PipelineOCIClient) readCache(manifestDigest digest.Digest) ([]byte, bool) {
+	path := c.cachePath(manifestDigest)
+	if path == "" {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *PipelineOCIClient) writeCache(manifestDigest digest.Digest, pipelineSpec []byte) {
+	path := c.cachePath(manifestDigest)
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(c.options.CacheDir, 0755); err != nil {
+		return
+	}
+	_ = ioutil.WriteFile(path, pipelineSpec, 0644)
+}
+
+// PulledPipeline is the result of resolving and downloading a pipeline
+// artifact from an OCI registry.
+type PulledPipeline struct {
+	// PipelineSpec is the downloaded, digest-verified pipeline IR.
+	PipelineSpec []byte
+	// ResolvedDigest is the manifest digest the reference resolved to.
+	ResolvedDigest string
+}
+
+// PullPipeline resolves ociRef (e.g. "registry/ns/pipeline@sha256:...", or a
+// tag reference), downloads its single KFPIRMediaType layer, verifies its
+// digest against the descriptor, and returns the pipeline spec bytes. namespace
+// is used to resolve which credentials to authenticate with.
+func (c *PipelineOCIClient) PullPipeline(ctx context.Context, namespace, ociRef string) (*PulledPipeline, error) {
+	if err := c.checkAllowed(ociRef); err != nil {
+		return nil, err
+	}
+	repo, err := c.remoteRepository(namespace, ociRef)
+	if err != nil {
+		return nil, err
+	}
+	ref := repo.Reference.ReferenceOrDefault()
+
+	manifestDesc, err := repo.Resolve(ctx, ref)
+	if err != nil {
+		return nil, util.Wrap(err, "Failed to resolve OCI reference")
+	}
+	if cached, ok := c.readCache(manifestDesc.Digest); ok {
+		return &PulledPipeline{PipelineSpec: cached, ResolvedDigest: manifestDesc.Digest.String()}, nil
+	}
+
+	dst := memory.New()
+	if _, err := oras.Copy(ctx, repo, ref, dst, "", oras.DefaultCopyOptions); err != nil {
+		return nil, util.Wrap(err, "Failed to pull pipeline artifact from OCI registry")
+	}
+
+	manifestBytes, err := content.FetchAll(ctx, dst, manifestDesc)
+	if err != nil {
+		return nil, util.Wrap(err, "Failed to read pulled OCI manifest")
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, util.Wrap(err, "Failed to parse pulled OCI manifest")
+	}
+
+	var pipelineLayer *ocispec.Descriptor
+	for i := range manifest.Layers {
+		if manifest.Layers[i].MediaType == KFPIRMediaType {
+			pipelineLayer = &manifest.Layers[i]
+			break
+		}
+	}
+	if pipelineLayer == nil {
+		return nil, errors.Errorf("OCI artifact %q has no layer of media type %q", ociRef, KFPIRMediaType)
+	}
+
+	pipelineSpec, err := content.FetchAll(ctx, dst, *pipelineLayer)
+	if err != nil {
+		return nil, util.Wrap(err, "Failed to fetch pipeline spec layer from OCI registry")
+	}
+	if digest.FromBytes(pipelineSpec) != pipelineLayer.Digest {
+		return nil, errors.Errorf("pipeline spec layer digest mismatch for %q", ociRef)
+	}
+
+	c.writeCache(manifestDesc.Digest, pipelineSpec)
+	return &PulledPipeline{PipelineSpec: pipelineSpec, ResolvedDigest: manifestDesc.Digest.String()}, nil
+}
+
+// PushPipeline packages pipelineSpec as a single-layer OCI artifact of media
+// type KFPIRMediaType and pushes it to ociRef.
+func (c *PipelineOCIClient) PushPipeline(ctx context.Context, namespace, ociRef string, pipelineSpec []byte) error {
+	if err := c.checkAllowed(ociRef); err != nil {
+		return err
+	}
+	repo, err := c.remoteRepository(namespace, ociRef)
+	if err != nil {
+		return err
+	}
+	ref := repo.Reference.ReferenceOrDefault()
+
+	src := memory.New()
+	layerDesc := content.NewDescriptorFromBytes(KFPIRMediaType, pipelineSpec)
+	if err := src.Push(ctx, layerDesc, bytes.NewReader(pipelineSpec)); err != nil {
+		return util.Wrap(err, "Failed to stage pipeline spec layer")
+	}
+
+	manifestDesc, err := oras.PackManifest(ctx, src, oras.PackManifestVersion1_1, KFPIRMediaType, oras.PackManifestOptions{
+		Layers: []ocispec.Descriptor{layerDesc},
+	})
+	if err != nil {
+		return util.Wrap(err, "Failed to pack OCI manifest")
+	}
+	if ref != "" {
+		if err := src.Tag(ctx, manifestDesc, ref); err != nil {
+			return util.Wrap(err, "Failed to tag OCI manifest")
+		}
+	}
+
+	if _, err := oras.Copy(ctx, src, manifestDesc.Digest.String(), repo, ref, oras.DefaultCopyOptions); err != nil {
+		return util.Wrap(err, "Failed to push pipeline artifact to OCI registry")
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"github.com/kubeflow/pipelines/backend/src/common/util"
+	"github.com/pkg/errors"
+)
+
+// VerificationPolicy controls whether a namespace requires a verified signature
+// before a pipeline (or pipeline version) upload is accepted.
+type VerificationPolicy string
+
+const (
+	// VerificationPolicyRequired rejects uploads that do not carry a signature
+	// that verifies successfully.
+	VerificationPolicyRequired VerificationPolicy = "required"
+	// VerificationPolicyOptional verifies a signature when one is supplied, but
+	// still accepts the upload when verification fails or no signature is present.
+	VerificationPolicyOptional VerificationPolicy = "optional"
+	// VerificationPolicyDisabled skips signature verification entirely.
+	VerificationPolicyDisabled VerificationPolicy = "disabled"
+)
+
+// VerifierBackend identifies which signing scheme a PipelineVerifier implementation
+// handles.
+type VerifierBackend string
+
+const (
+	// VerifierBackendCosignKeyless verifies signatures produced by cosign's keyless
+	// flow, using Fulcio for certificate issuance and Rekor for transparency log
+	// inclusion.
+	VerifierBackendCosignKeyless VerifierBackend = "cosign-keyless"
+	// VerifierBackendStaticKey verifies signatures against a fixed public key
+	// configured on the server.
+	VerifierBackendStaticKey VerifierBackend = "static-key"
+	// VerifierBackendCosignKeyRef verifies signatures using a cosign key reference
+	// (e.g. a KMS URI or a path to a key file).
+	VerifierBackendCosignKeyRef VerifierBackend = "cosign-key-ref"
+)
+
+// SignedPipelineFile bundles the pipeline file bytes that were uploaded alongside
+// the optional detached signature material used to verify them.
+type SignedPipelineFile struct {
+	// Signature is the raw detached signature bytes. When it is empty, the
+	// signature carried in Bundle is used instead.
+	Signature []byte
+	// Certificate is the signer's certificate, present for keyless verification.
+	Certificate []byte
+	// Bundle is a Sigstore bundle (signature + certificate + Rekor entry) supplied
+	// as a single artifact, as an alternative to Signature/Certificate.
+	Bundle []byte
+	// Attestation is an optional DSSE-enveloped in-toto attestation over the
+	// pipeline file, verified against the same key/certificate as Signature
+	// once that signature itself checks out.
+	Attestation []byte
+}
+
+// HasMaterial reports whether the caller supplied any signature material at all.
+func (f *SignedPipelineFile) HasMaterial() bool {
+	return f != nil && (len(f.Signature) > 0 || len(f.Bundle) > 0)
+}
+
+// VerifiedAttestation captures the outcome of a successful verification, to be
+// persisted alongside the pipeline record so it can be re-exposed on GET.
+type VerifiedAttestation struct {
+	// SubjectDigest is the sha256 digest of the verified pipeline file, in
+	// "sha256:<hex>" form.
+	SubjectDigest string
+	// Backend is the verifier backend that produced this attestation.
+	Backend VerifierBackend
+	// Issuer identifies the OIDC issuer or key reference that signed the subject.
+	Issuer string
+	// RekorLogIndex is the Rekor transparency log index, when the backend is
+	// cosign-keyless.
+	RekorLogIndex int64
+	// InTotoPredicateType is the predicate type of the accompanying in-toto
+	// attestation, when present.
+	InTotoPredicateType string
+}
+
+// PipelineVerifier verifies a detached signature (and optional in-toto
+// attestation) over a pipeline file. Implementations are pluggable backends;
+// PipelineVerifierManager uses its single default backend for every namespace.
+type PipelineVerifier interface {
+	// Backend identifies which scheme this implementation verifies.
+	Backend() VerifierBackend
+	// Verify checks signed over pipelineFile and returns the resulting
+	// attestation metadata, or an error if verification fails.
+	Verify(pipelineFile []byte, signed *SignedPipelineFile) (*VerifiedAttestation, error)
+}
+
+// PipelineVerifierManager resolves the policy to use for a given namespace and
+// runs verification with the default backend. The policy can differ per
+// namespace; the backend cannot.
+type PipelineVerifierManager struct {
+	backends          map[VerifierBackend]PipelineVerifier
+	defaultBackend    VerifierBackend
+	policyByNamespace map[string]VerificationPolicy
+	defaultPolicy     VerificationPolicy
+}
+
+// NewPipelineVerifierManager constructs a manager from the configured backends,
+// the backend to verify with in every namespace, and the
+// required/optional/disabled policy map read from server flags.
+func NewPipelineVerifierManager(
+	backends []PipelineVerifier,
+	defaultBackend VerifierBackend,
+	policyByNamespace map[string]VerificationPolicy,
+	defaultPolicy VerificationPolicy,
+) (*PipelineVerifierManager, error) {
+	backendMap := make(map[VerifierBackend]PipelineVerifier, len(backends))
+	for _, b := range backends {
+		backendMap[b.Backend()] = b
+	}
+	if _, ok := backendMap[defaultBackend]; !ok && len(backends) > 0 {
+		return nil, errors.Errorf("default verifier backend %q was not among the configured backends", defaultBackend)
+	}
+	return &PipelineVerifierManager{
+		backends:          backendMap,
+		defaultBackend:    defaultBackend,
+		policyByNamespace: policyByNamespace,
+		defaultPolicy:     defaultPolicy,
+	}, nil
+}
+
+// PolicyForNamespace returns the verification policy in effect for namespace,
+// falling back to the server-wide default when the namespace has no override.
+func (m *PipelineVerifierManager) PolicyForNamespace(namespace string) VerificationPolicy {
+	if policy, ok := m.policyByNamespace[namespace]; ok {
+		return policy
+	}
+	return m.defaultPolicy
+}
+
+// VerifyForNamespace enforces the policy configured for namespace against the
+// supplied signature material. It returns (nil, nil) when verification is not
+// required and no material was supplied.
+func (m *PipelineVerifierManager) VerifyForNamespace(namespace string, pipelineFile []byte, signed *SignedPipelineFile) (*VerifiedAttestation, error) {
+	policy := m.PolicyForNamespace(namespace)
+	if policy == VerificationPolicyDisabled {
+		return nil, nil
+	}
+	if !signed.HasMaterial() {
+		if policy == VerificationPolicyRequired {
+			return nil, util.NewInvalidInputError("A verified signature is required to upload a pipeline in namespace %q.", namespace)
+		}
+		return nil, nil
+	}
+
+	backend, ok := m.backends[m.defaultBackend]
+	if !ok {
+		return nil, errors.Errorf("no pipeline verifier configured for backend %q", m.defaultBackend)
+	}
+	attestation, err := backend.Verify(pipelineFile, signed)
+	if err != nil {
+		if policy == VerificationPolicyRequired {
+			return nil, util.Wrap(err, "Pipeline signature verification failed")
+		}
+		return nil, nil
+	}
+	return attestation, nil
+}
@@ -0,0 +1,467 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// verifySignature checks sig over the sha256 digest of data using pub,
+// supporting the two key types cosign itself issues: ECDSA (P-256, used by
+// Fulcio-issued certs and most static keys) and Ed25519.
+func verifySignature(pub crypto.PublicKey, data, sig []byte) error {
+	digest := sha256.Sum256(data)
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], sig) {
+			return errors.New("ECDSA signature verification failed")
+		}
+		return nil
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, digest[:], sig) {
+			return errors.New("Ed25519 signature verification failed")
+		}
+		return nil
+	default:
+		return errors.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+func subjectDigest(data []byte) string {
+	digest := sha256.Sum256(data)
+	return "sha256:" + hexEncode(digest[:])
+}
+
+func hexEncode(b []byte) string {
+	const hextable = "0123456789abcdef"
+	out := make([]byte, len(b)*2)
+	for i, v := range b {
+		out[i*2] = hextable[v>>4]
+		out[i*2+1] = hextable[v&0x0f]
+	}
+	return string(out)
+}
+
+func parsePEMPublicKey(pemBytes []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("not a PEM-encoded public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse PKIX public key")
+	}
+	return pub, nil
+}
+
+// dsseEnvelope is a DSSE (Dead Simple Signing Envelope, used by in-toto
+// attestations) carrying a base64 payload and one or more signatures over its
+// pre-authentication encoding.
+type dsseEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"`
+	Signatures  []dsseSignature `json:"signatures"`
+}
+
+type dsseSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// inTotoStatement is the minimal subset of an in-toto Statement this package
+// reads: enough to bind the attestation to the pipeline file it accompanies
+// via its subject digest.
+type inTotoStatement struct {
+	PredicateType string          `json:"predicateType"`
+	Subject       []inTotoSubject `json:"subject"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// dssePAE computes the DSSE v1 pre-authentication encoding of payloadType and
+// payload: the exact bytes a DSSE signature is produced (and must be
+// verified) over, per https://github.com/secure-systems-lab/dsse/blob/master/protocol.md.
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// verifyInTotoAttestation checks a DSSE-enveloped in-toto attestation against
+// pub and confirms its subject binds to pipelineFile by sha256 digest,
+// returning the attestation's predicate type. Returns ("", nil) when envelope
+// is empty, since an in-toto attestation is optional alongside the detached
+// signature.
+func verifyInTotoAttestation(pub crypto.PublicKey, pipelineFile, envelope []byte) (string, error) {
+	if len(envelope) == 0 {
+		return "", nil
+	}
+	var dsse dsseEnvelope
+	if err := json.Unmarshal(envelope, &dsse); err != nil {
+		return "", errors.Wrap(err, "failed to parse DSSE attestation envelope")
+	}
+	if len(dsse.Signatures) == 0 {
+		return "", errors.New("DSSE attestation envelope has no signatures")
+	}
+	payload, err := base64.StdEncoding.DecodeString(dsse.Payload)
+	if err != nil {
+		return "", errors.Wrap(err, "DSSE attestation payload is not valid base64")
+	}
+	pae := dssePAE(dsse.PayloadType, payload)
+
+	var verifyErr error
+	verified := false
+	for _, sig := range dsse.Signatures {
+		raw, err := base64.StdEncoding.DecodeString(sig.Sig)
+		if err != nil {
+			verifyErr = err
+			continue
+		}
+		if err := verifySignature(pub, pae, raw); err != nil {
+			verifyErr = err
+			continue
+		}
+		verified = true
+		break
+	}
+	if !verified {
+		return "", errors.Wrap(verifyErr, "no DSSE signature on the in-toto attestation verified against the signer's key")
+	}
+
+	var statement inTotoStatement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return "", errors.Wrap(err, "failed to parse in-toto statement")
+	}
+	want := subjectDigest(pipelineFile)
+	for _, subject := range statement.Subject {
+		if sha256Hex, ok := subject.Digest["sha256"]; ok && "sha256:"+sha256Hex == want {
+			return statement.PredicateType, nil
+		}
+	}
+	return "", errors.Errorf("in-toto attestation subject does not bind to the uploaded pipeline file (want %s)", want)
+}
+
+// StaticKeyVerifier implements VerifierBackendStaticKey: signatures are
+// checked against a single, server-configured public key.
+type StaticKeyVerifier struct {
+	publicKey crypto.PublicKey
+	issuer    string
+}
+
+// NewStaticKeyVerifier constructs a StaticKeyVerifier from a PEM-encoded
+// PKIX public key, as configured via the server's static-key flag. issuer is
+// a free-form label (e.g. the key's owner) recorded on successful
+// verifications.
+func NewStaticKeyVerifier(publicKeyPEM []byte, issuer string) (*StaticKeyVerifier, error) {
+	pub, err := parsePEMPublicKey(publicKeyPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid static public key")
+	}
+	return &StaticKeyVerifier{publicKey: pub, issuer: issuer}, nil
+}
+
+func (v *StaticKeyVerifier) Backend() VerifierBackend { return VerifierBackendStaticKey }
+
+func (v *StaticKeyVerifier) Verify(pipelineFile []byte, signed *SignedPipelineFile) (*VerifiedAttestation, error) {
+	sig := signed.Signature
+	if len(sig) == 0 && len(signed.Bundle) > 0 {
+		bundle, err := decodeSigstoreBundle(signed.Bundle)
+		if err != nil {
+			return nil, err
+		}
+		sig = bundle.Signature
+	}
+	if len(sig) == 0 {
+		return nil, errors.New("no signature supplied")
+	}
+	if err := verifySignature(v.publicKey, pipelineFile, sig); err != nil {
+		return nil, err
+	}
+	predicateType, err := verifyInTotoAttestation(v.publicKey, pipelineFile, signed.Attestation)
+	if err != nil {
+		return nil, err
+	}
+	return &VerifiedAttestation{
+		SubjectDigest:       subjectDigest(pipelineFile),
+		Backend:             VerifierBackendStaticKey,
+		Issuer:              v.issuer,
+		InTotoPredicateType: predicateType,
+	}, nil
+}
+
+// KeyRefResolver resolves a cosign key reference (a KMS URI, or a path to a
+// key file) to the public key it names. The default resolver handles
+// file-backed PEM keys; a KMS-backed resolver can be supplied for
+// e.g. "awskms://..." / "gcpkms://..." refs.
+type KeyRefResolver func(keyRef string) (crypto.PublicKey, error)
+
+// FileKeyRefResolver is a KeyRefResolver that reads a PEM-encoded PKIX public
+// key from a local file path.
+func FileKeyRefResolver(readFile func(path string) ([]byte, error)) KeyRefResolver {
+	return func(keyRef string) (crypto.PublicKey, error) {
+		data, err := readFile(keyRef)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read key ref %q", keyRef)
+		}
+		return parsePEMPublicKey(data)
+	}
+}
+
+// CosignKeyRefVerifier implements VerifierBackendCosignKeyRef: signatures are
+// checked against a public key resolved (once, at construction) from a
+// cosign key reference such as a KMS URI or key file path.
+type CosignKeyRefVerifier struct {
+	publicKey crypto.PublicKey
+	keyRef    string
+}
+
+// NewCosignKeyRefVerifier resolves keyRef via resolver and constructs a
+// CosignKeyRefVerifier bound to the resulting public key.
+func NewCosignKeyRefVerifier(keyRef string, resolver KeyRefResolver) (*CosignKeyRefVerifier, error) {
+	pub, err := resolver(keyRef)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve cosign key ref %q", keyRef)
+	}
+	return &CosignKeyRefVerifier{publicKey: pub, keyRef: keyRef}, nil
+}
+
+func (v *CosignKeyRefVerifier) Backend() VerifierBackend { return VerifierBackendCosignKeyRef }
+
+func (v *CosignKeyRefVerifier) Verify(pipelineFile []byte, signed *SignedPipelineFile) (*VerifiedAttestation, error) {
+	sig := signed.Signature
+	if len(sig) == 0 && len(signed.Bundle) > 0 {
+		bundle, err := decodeSigstoreBundle(signed.Bundle)
+		if err != nil {
+			return nil, err
+		}
+		sig = bundle.Signature
+	}
+	if len(sig) == 0 {
+		return nil, errors.New("no signature supplied")
+	}
+	if err := verifySignature(v.publicKey, pipelineFile, sig); err != nil {
+		return nil, err
+	}
+	predicateType, err := verifyInTotoAttestation(v.publicKey, pipelineFile, signed.Attestation)
+	if err != nil {
+		return nil, err
+	}
+	return &VerifiedAttestation{
+		SubjectDigest:       subjectDigest(pipelineFile),
+		Backend:             VerifierBackendCosignKeyRef,
+		Issuer:              v.keyRef,
+		InTotoPredicateType: predicateType,
+	}, nil
+}
+
+// sigstoreBundle is the minimal subset of a Sigstore bundle this package
+// reads: the detached signature, the signer's leaf certificate (keyless
+// flow only), and the Rekor transparency log entry backing it.
+type sigstoreBundle struct {
+	Signature     []byte          `json:"signature"`
+	Certificate   []byte          `json:"certificate"`
+	RekorLogIndex int64           `json:"rekorLogIndex"`
+	RekorEntry    json.RawMessage `json:"rekorEntry"`
+	RekorSET      []byte          `json:"rekorSignedEntryTimestamp"`
+}
+
+func decodeSigstoreBundle(raw []byte) (*sigstoreBundle, error) {
+	var bundle sigstoreBundle
+	if err := json.Unmarshal(raw, &bundle); err != nil {
+		return nil, errors.Wrap(err, "failed to parse Sigstore bundle")
+	}
+	return &bundle, nil
+}
+
+// fulcioIssuerOID is the X.509 extension Fulcio embeds in every certificate
+// it issues, identifying the OIDC provider that authenticated the signer
+// (e.g. "https://accounts.google.com", "https://token.actions.githubusercontent.com").
+var fulcioIssuerOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+
+// AllowedIdentity is one signer identity CosignKeylessVerifier accepts.
+// Fulcio will issue a valid short-lived certificate to any authenticated OIDC
+// account, so a certificate merely chaining to a Fulcio root only proves
+// "signed by someone who completed an OIDC flow", not "signed by someone we
+// trust" -- that requires also pinning the SAN/issuer the cert was issued for.
+type AllowedIdentity struct {
+	// SAN is the exact Subject Alternative Name (an email address, or a
+	// workload identity URI for keyless CI signing) the signer's certificate
+	// must carry.
+	SAN string
+	// Issuer is the OIDC issuer URL that must have issued the certificate. May
+	// be "" to accept SAN from any issuer.
+	Issuer string
+}
+
+func certIssuerURL(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(fulcioIssuerOID) {
+			continue
+		}
+		var issuer string
+		if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+			return issuer
+		}
+		return string(ext.Value)
+	}
+	return ""
+}
+
+func certSANs(cert *x509.Certificate) []string {
+	sans := make([]string, 0, len(cert.EmailAddresses)+len(cert.URIs))
+	sans = append(sans, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
+
+// CosignKeylessVerifier implements VerifierBackendCosignKeyless: the signer's
+// certificate is validated against a Fulcio root of trust, its SAN/issuer is
+// checked against AllowedIdentities (when configured), the signature is
+// checked against the certificate's public key, and (when a Rekor public key
+// is configured) the transparency log entry's Signed Entry Timestamp is
+// checked against it.
+type CosignKeylessVerifier struct {
+	fulcioRoots       *x509.CertPool
+	rekorPublicKey    crypto.PublicKey // optional
+	allowedIdentities []AllowedIdentity
+}
+
+// NewCosignKeylessVerifier constructs a CosignKeylessVerifier trusting
+// certificates chained to fulcioRootsPEM. rekorPublicKeyPEM may be nil to
+// skip Rekor SET verification (e.g. when only enforcing "signed by our CI",
+// with transparency logging checked elsewhere). allowedIdentities may be
+// empty, but doing so accepts a signature from any Fulcio-issued certificate
+// regardless of who it was issued to -- production deployments should always
+// set it.
+func NewCosignKeylessVerifier(fulcioRootsPEM []byte, rekorPublicKeyPEM []byte, allowedIdentities []AllowedIdentity) (*CosignKeylessVerifier, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(fulcioRootsPEM) {
+		return nil, errors.New("no valid certificates found in Fulcio root PEM")
+	}
+	v := &CosignKeylessVerifier{fulcioRoots: pool, allowedIdentities: allowedIdentities}
+	if len(rekorPublicKeyPEM) > 0 {
+		rekorPub, err := parsePEMPublicKey(rekorPublicKeyPEM)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid Rekor public key")
+		}
+		v.rekorPublicKey = rekorPub
+	}
+	return v, nil
+}
+
+// matchesAllowedIdentity reports whether cert's SAN/issuer satisfies at least
+// one configured AllowedIdentity, or true when no allowlist is configured.
+func (v *CosignKeylessVerifier) matchesAllowedIdentity(cert *x509.Certificate) bool {
+	if len(v.allowedIdentities) == 0 {
+		return true
+	}
+	issuer := certIssuerURL(cert)
+	sans := certSANs(cert)
+	for _, policy := range v.allowedIdentities {
+		if policy.Issuer != "" && policy.Issuer != issuer {
+			continue
+		}
+		for _, san := range sans {
+			if san == policy.SAN {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (v *CosignKeylessVerifier) Backend() VerifierBackend { return VerifierBackendCosignKeyless }
+
+func (v *CosignKeylessVerifier) Verify(pipelineFile []byte, signed *SignedPipelineFile) (*VerifiedAttestation, error) {
+	certPEM := signed.Certificate
+	sig := signed.Signature
+	var bundle *sigstoreBundle
+	if len(signed.Bundle) > 0 {
+		var err error
+		bundle, err = decodeSigstoreBundle(signed.Bundle)
+		if err != nil {
+			return nil, err
+		}
+		if len(certPEM) == 0 {
+			certPEM = bundle.Certificate
+		}
+		if len(sig) == 0 {
+			sig = bundle.Signature
+		}
+	}
+	if len(certPEM) == 0 || len(sig) == 0 {
+		return nil, errors.New("keyless verification requires both a signature and a signer certificate")
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, errors.New("signer certificate is not valid PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse signer certificate")
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:     v.fulcioRoots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, errors.Wrap(err, "signer certificate does not chain to a trusted Fulcio root")
+	}
+	if !v.matchesAllowedIdentity(cert) {
+		return nil, errors.Errorf("signer certificate identity (issuer=%q, sans=%v) is not in the allowed identity list", certIssuerURL(cert), certSANs(cert))
+	}
+
+	if err := verifySignature(cert.PublicKey, pipelineFile, sig); err != nil {
+		return nil, err
+	}
+	predicateType, err := verifyInTotoAttestation(cert.PublicKey, pipelineFile, signed.Attestation)
+	if err != nil {
+		return nil, err
+	}
+
+	attestation := &VerifiedAttestation{
+		SubjectDigest:       subjectDigest(pipelineFile),
+		Backend:             VerifierBackendCosignKeyless,
+		Issuer:              certIssuerURL(cert),
+		InTotoPredicateType: predicateType,
+	}
+
+	if v.rekorPublicKey != nil {
+		if bundle == nil || len(bundle.RekorSET) == 0 || len(bundle.RekorEntry) == 0 {
+			return nil, errors.New("Rekor entry is missing its Signed Entry Timestamp")
+		}
+		if err := verifySignature(v.rekorPublicKey, bundle.RekorEntry, bundle.RekorSET); err != nil {
+			return nil, errors.Wrap(err, "Rekor Signed Entry Timestamp verification failed")
+		}
+	}
+	if bundle != nil && bundle.RekorLogIndex > 0 {
+		attestation.RekorLogIndex = bundle.RekorLogIndex
+	}
+	return attestation, nil
+}
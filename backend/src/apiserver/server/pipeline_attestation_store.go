@@ -0,0 +1,100 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/kubeflow/pipelines/backend/src/apiserver/resource"
+	"github.com/pkg/errors"
+)
+
+// PipelineAttestationStore persists the VerifiedAttestation produced for a
+// pipeline (or pipeline version) upload, keyed by the pipeline/version's
+// resource ID, so GetPipelineAttestation can re-expose it on GET as the
+// request requires.
+type PipelineAttestationStore interface {
+	// PutAttestation stores attestation for resourceId (a pipeline UUID or
+	// pipeline version UUID).
+	PutAttestation(resourceId string, attestation *resource.VerifiedAttestation) error
+	// GetAttestation returns the attestation stored for resourceId, or an error
+	// if none was recorded (e.g. the upload was unsigned).
+	GetAttestation(resourceId string) (*resource.VerifiedAttestation, error)
+}
+
+// InMemoryPipelineAttestationStore is a PipelineAttestationStore backed by a
+// process-local map, suitable for a single-replica server or for tests.
+type InMemoryPipelineAttestationStore struct {
+	mu   sync.RWMutex
+	data map[string]*resource.VerifiedAttestation
+}
+
+// NewInMemoryPipelineAttestationStore constructs an empty
+// InMemoryPipelineAttestationStore.
+func NewInMemoryPipelineAttestationStore() *InMemoryPipelineAttestationStore {
+	return &InMemoryPipelineAttestationStore{data: make(map[string]*resource.VerifiedAttestation)}
+}
+
+func (s *InMemoryPipelineAttestationStore) PutAttestation(resourceId string, attestation *resource.VerifiedAttestation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[resourceId] = attestation
+	return nil
+}
+
+func (s *InMemoryPipelineAttestationStore) GetAttestation(resourceId string) (*resource.VerifiedAttestation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	attestation, ok := s.data[resourceId]
+	if !ok {
+		return nil, errors.Errorf("No verified attestation recorded for %q", resourceId)
+	}
+	return attestation, nil
+}
+
+// recordAttestation stores attestation for resourceId if both a store is
+// configured and an attestation was actually produced (a namespace with
+// VerificationPolicyOptional and no signature supplied produces no
+// attestation).
+func (s *PipelineUploadServer) recordAttestation(resourceId string, attestation *resource.VerifiedAttestation) {
+	if attestation == nil || s.options.AttestationStore == nil {
+		return
+	}
+	if err := s.options.AttestationStore.PutAttestation(resourceId, attestation); err != nil {
+		glog.Warningf("Failed to persist verified attestation for %q: %v", resourceId, err)
+	}
+}
+
+// GetPipelineAttestation re-exposes the verified subject digest and
+// attestation metadata recorded for a signed pipeline upload. Routed as
+// GET /pipelines/{id}/attestation.
+func (s *PipelineUploadServer) GetPipelineAttestation(w http.ResponseWriter, r *http.Request, pipelineId string) {
+	if s.options.AttestationStore == nil {
+		s.writeErrorToResponse(w, http.StatusNotFound, errors.New("This server does not verify pipeline upload signatures."))
+		return
+	}
+	attestation, err := s.options.AttestationStore.GetAttestation(pipelineId)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(attestation); err != nil {
+		s.writeErrorToResponse(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"archive/tar"
+
+	"github.com/kubeflow/pipelines/backend/src/apiserver/common"
+	"github.com/kubeflow/pipelines/backend/src/common/util"
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+const (
+	// BundlePipelineSpecPath is the required location of the main pipeline spec
+	// within a pipeline bundle.
+	BundlePipelineSpecPath = "pipeline.yaml"
+	// BundleComponentsDir is the directory within a pipeline bundle holding
+	// reusable component specs that pipeline.yaml may reference.
+	BundleComponentsDir = "components/"
+	// BundleResourcesDir is the directory within a pipeline bundle holding
+	// small static assets referenced by the pipeline or its components.
+	BundleResourcesDir = "resources/"
+	// MaxBundleTotalSize is the maximum combined size of all files in a
+	// pipeline bundle.
+	MaxBundleTotalSize = 100 * 1024 * 1024
+	// MaxBundleFileSize is the maximum size of any single file within a
+	// pipeline bundle.
+	MaxBundleFileSize = 20 * 1024 * 1024
+	// MaxBundleFileCount is the maximum number of files a pipeline bundle may
+	// contain.
+	MaxBundleFileCount = 1000
+)
+
+// PipelineBundle is the result of reading and validating a multi-file
+// pipeline bundle: the main pipeline spec, plus every file under components/
+// and resources/, content-addressed by the sha256 of its bytes so
+// pipeline.yaml's importer references can resolve them later.
+type PipelineBundle struct {
+	// PipelineSpec is the contents of the bundle's top-level pipeline.yaml.
+	PipelineSpec []byte
+	// SubArtifacts maps a sha256 hex digest to the bytes of a file under
+	// components/ or resources/.
+	SubArtifacts map[string][]byte
+}
+
+// IsBundleFilename reports whether filename's extension indicates it is a
+// pipeline bundle (zip or tar.gz) rather than a single pipeline spec file.
+func IsBundleFilename(filename string) bool {
+	lower := strings.ToLower(filename)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// ReadBundle validates and reads a pipeline bundle (zip or tar.gz) from r,
+// enforcing per-file and total size limits and rejecting path traversal.
+func ReadBundle(filename string, r io.Reader) (*PipelineBundle, error) {
+	content, err := ioutil.ReadAll(io.LimitReader(r, MaxBundleTotalSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read pipeline bundle")
+	}
+	if int64(len(content)) > MaxBundleTotalSize {
+		return nil, errors.Errorf("Pipeline bundle exceeds the maximum total size of %d bytes", MaxBundleTotalSize)
+	}
+
+	lower := strings.ToLower(filename)
+	var files map[string][]byte
+	if strings.HasSuffix(lower, ".zip") {
+		files, err = readZipBundle(content)
+	} else {
+		files, err = readTarGzBundle(content)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buildBundle(files)
+}
+
+func readZipBundle(content []byte) (map[string][]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, errors.Wrap(err, "Not a valid zip pipeline bundle")
+	}
+	files := make(map[string][]byte, len(zr.File))
+	var totalUncompressed int64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if len(files) >= MaxBundleFileCount {
+			return nil, errors.Errorf("Pipeline bundle exceeds the maximum file count of %d", MaxBundleFileCount)
+		}
+		cleanName, err := sanitizeBundlePath(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if f.UncompressedSize64 > MaxBundleFileSize {
+			return nil, errors.Errorf("Bundle file %q exceeds the maximum file size of %d bytes", cleanName, MaxBundleFileSize)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to open bundle file %q", cleanName)
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(rc, MaxBundleFileSize+1))
+		rc.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read bundle file %q", cleanName)
+		}
+		if int64(len(data)) > MaxBundleFileSize {
+			return nil, errors.Errorf("Bundle file %q exceeds the maximum file size of %d bytes", cleanName, MaxBundleFileSize)
+		}
+		// Compressed total size is capped before we ever get here, but a
+		// maliciously crafted archive can still claim a small compressed size
+		// while expanding to far more uncompressed bytes across many entries
+		// (a zip-bomb). Track the running uncompressed total across all
+		// entries and abort as soon as it exceeds MaxBundleTotalSize, rather
+		// than only bounding each entry individually.
+		totalUncompressed += int64(len(data))
+		if totalUncompressed > MaxBundleTotalSize {
+			return nil, errors.Errorf("Pipeline bundle's uncompressed contents exceed the maximum total size of %d bytes", MaxBundleTotalSize)
+		}
+		files[cleanName] = data
+	}
+	return files, nil
+}
+
+func readTarGzBundle(content []byte) (map[string][]byte, error) {
+	gzr, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Wrap(err, "Not a valid tar.gz pipeline bundle")
+	}
+	defer gzr.Close()
+
+	files := make(map[string][]byte)
+	var totalUncompressed int64
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to read tar.gz pipeline bundle")
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if len(files) >= MaxBundleFileCount {
+			return nil, errors.Errorf("Pipeline bundle exceeds the maximum file count of %d", MaxBundleFileCount)
+		}
+		cleanName, err := sanitizeBundlePath(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Size > MaxBundleFileSize {
+			return nil, errors.Errorf("Bundle file %q exceeds the maximum file size of %d bytes", cleanName, MaxBundleFileSize)
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(tr, MaxBundleFileSize+1))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read bundle file %q", cleanName)
+		}
+		if int64(len(data)) > MaxBundleFileSize {
+			return nil, errors.Errorf("Bundle file %q exceeds the maximum file size of %d bytes", cleanName, MaxBundleFileSize)
+		}
+		// See the matching comment in readZipBundle: gzip, like zip, can
+		// expand a small compressed stream into many large entries, so the
+		// running uncompressed total across all entries is checked here too.
+		totalUncompressed += int64(len(data))
+		if totalUncompressed > MaxBundleTotalSize {
+			return nil, errors.Errorf("Pipeline bundle's uncompressed contents exceed the maximum total size of %d bytes", MaxBundleTotalSize)
+		}
+		files[cleanName] = data
+	}
+	return files, nil
+}
+
+// sanitizeBundlePath rejects absolute paths and ".." traversal, and returns
+// the slash-cleaned path.
+func sanitizeBundlePath(name string) (string, error) {
+	cleaned := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", errors.Errorf("Bundle entry %q escapes the bundle root", name)
+	}
+	return cleaned, nil
+}
+
+// buildBundle validates that files contains a top-level pipeline.yaml and that
+// every other file lives under components/ or resources/, then
+// content-addresses the non-spec files by sha256.
+func buildBundle(files map[string][]byte) (*PipelineBundle, error) {
+	spec, ok := files[BundlePipelineSpecPath]
+	if !ok {
+		return nil, errors.Errorf("Pipeline bundle is missing the required top-level %q", BundlePipelineSpecPath)
+	}
+
+	subArtifacts := make(map[string][]byte, len(files)-1)
+	for name, data := range files {
+		if name == BundlePipelineSpecPath {
+			continue
+		}
+		if !strings.HasPrefix(name, BundleComponentsDir) && !strings.HasPrefix(name, BundleResourcesDir) {
+			return nil, errors.Errorf("Bundle entry %q must live under %q or %q", name, BundleComponentsDir, BundleResourcesDir)
+		}
+		sum := sha256.Sum256(data)
+		subArtifacts[hex.EncodeToString(sum[:])] = data
+	}
+
+	return &PipelineBundle{PipelineSpec: spec, SubArtifacts: subArtifacts}, nil
+}
+
+// PipelineComponentStore persists a pipeline bundle's content-addressed
+// sub-artifacts so GetPipelineComponent can serve them back by pipeline ID and
+// sha256 digest.
+type PipelineComponentStore interface {
+	// PutComponents stores artifacts for pipelineId, keyed by sha256 hex digest.
+	PutComponents(pipelineId string, artifacts map[string][]byte) error
+	// GetComponent returns the artifact bytes for pipelineId/sha, or an error if
+	// absent.
+	GetComponent(pipelineId, sha string) ([]byte, error)
+}
+
+// InMemoryPipelineComponentStore is a PipelineComponentStore backed by a
+// process-local map, suitable for a single-replica server or for tests.
+type InMemoryPipelineComponentStore struct {
+	mu   sync.RWMutex
+	data map[string]map[string][]byte
+}
+
+// NewInMemoryPipelineComponentStore constructs an empty
+// InMemoryPipelineComponentStore.
+func NewInMemoryPipelineComponentStore() *InMemoryPipelineComponentStore {
+	return &InMemoryPipelineComponentStore{data: make(map[string]map[string][]byte)}
+}
+
+func (s *InMemoryPipelineComponentStore) PutComponents(pipelineId string, artifacts map[string][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[pipelineId] = artifacts
+	return nil
+}
+
+func (s *InMemoryPipelineComponentStore) GetComponent(pipelineId, sha string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	artifacts, ok := s.data[pipelineId]
+	if !ok {
+		return nil, errors.Errorf("No bundle components stored for pipeline %q", pipelineId)
+	}
+	data, ok := artifacts[sha]
+	if !ok {
+		return nil, errors.Errorf("No bundle component %q stored for pipeline %q", sha, pipelineId)
+	}
+	return data, nil
+}
+
+// GetPipelineComponent serves back a single sub-artifact of a bundle uploaded
+// for pipelineId, addressed by its sha256 digest. Routed as
+// GET /pipelines/{id}/components/{sha}.
+func (s *PipelineUploadServer) GetPipelineComponent(w http.ResponseWriter, r *http.Request, pipelineId, sha string) {
+	if s.options.ComponentStore == nil {
+		s.writeErrorToResponse(w, http.StatusNotFound, errors.New("This server was not uploaded any pipeline bundles."))
+		return
+	}
+
+	namespace, err := s.resourceManager.GetNamespaceFromPipelineID(pipelineId)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Failed to get namespace from pipelineId."))
+		return
+	}
+	resourceAttributes := &authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      common.RbacResourceVerbGet,
+	}
+	if err = s.canUploadVersionedPipeline(r, pipelineId, resourceAttributes); err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Authorization to namespace failed."))
+		return
+	}
+
+	data, err := s.options.ComponentStore.GetComponent(pipelineId, sha)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+// PipelineManifestStore persists the original, pre-conversion manifest of a
+// pipeline uploaded in a non-native format (Argo/Tekton), so it can be
+// retrieved later for round-trip fidelity even though the pipeline record
+// itself stores the converted KFP spec.
+type PipelineManifestStore interface {
+	// PutOriginalManifest stores manifest for pipelineId.
+	PutOriginalManifest(pipelineId string, manifest []byte) error
+	// GetOriginalManifest returns the manifest stored for pipelineId, or an
+	// error if none was recorded (e.g. the pipeline was uploaded as native KFP).
+	GetOriginalManifest(pipelineId string) ([]byte, error)
+}
+
+// InMemoryPipelineManifestStore is a PipelineManifestStore backed by a
+// process-local map, suitable for a single-replica server or for tests.
+type InMemoryPipelineManifestStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewInMemoryPipelineManifestStore constructs an empty
+// InMemoryPipelineManifestStore.
+func NewInMemoryPipelineManifestStore() *InMemoryPipelineManifestStore {
+	return &InMemoryPipelineManifestStore{data: make(map[string][]byte)}
+}
+
+func (s *InMemoryPipelineManifestStore) PutOriginalManifest(pipelineId string, manifest []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[pipelineId] = manifest
+	return nil
+}
+
+func (s *InMemoryPipelineManifestStore) GetOriginalManifest(pipelineId string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	manifest, ok := s.data[pipelineId]
+	if !ok {
+		return nil, errors.Errorf("No original manifest recorded for pipeline %q", pipelineId)
+	}
+	return manifest, nil
+}
+
+// recordOriginalManifest stores manifest for pipelineId if both a store is
+// configured and a non-native manifest was actually converted (a native KFP
+// upload has nothing to record: the original and converted spec are
+// identical).
+func (s *PipelineUploadServer) recordOriginalManifest(pipelineId string, manifest []byte) {
+	if len(manifest) == 0 || s.options.ManifestStore == nil {
+		return
+	}
+	if err := s.options.ManifestStore.PutOriginalManifest(pipelineId, manifest); err != nil {
+		glog.Warningf("Failed to persist original manifest for %q: %v", pipelineId, err)
+	}
+}
+
+// GetPipelineOriginalManifest serves back the pre-conversion Argo/Tekton
+// manifest for a pipeline uploaded in a non-native format. Routed as
+// GET /pipelines/{id}/original_manifest.
+func (s *PipelineUploadServer) GetPipelineOriginalManifest(w http.ResponseWriter, r *http.Request, pipelineId string) {
+	if s.options.ManifestStore == nil {
+		s.writeErrorToResponse(w, http.StatusNotFound, errors.New("This server does not retain original pipeline manifests."))
+		return
+	}
+	manifest, err := s.options.ManifestStore.GetOriginalManifest(pipelineId)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(manifest)
+}
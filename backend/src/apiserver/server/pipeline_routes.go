@@ -0,0 +1,79 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RegisterHandlers mounts every HTTP endpoint PipelineUploadServer
+// implements onto mux. None of these endpoints are exposed through the
+// grpc-gateway (see the comment on UploadPipeline), so they have to be wired
+// up directly here rather than through generated routes.
+func (s *PipelineUploadServer) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/apis/v1beta1/pipelines/upload", s.UploadPipeline)
+	mux.HandleFunc("/apis/v1beta1/pipelines/upload_version", s.UploadPipelineVersion)
+	mux.HandleFunc("/apis/v1beta1/pipelines/upload_oci", s.UploadPipelineFromOCI)
+	mux.HandleFunc("/apis/v1beta1/pipelines/push_oci", s.PushPipelineToOCI)
+	mux.HandleFunc("/apis/v1beta1/pipelines/upload/resumable", s.CreateTusUpload)
+	mux.HandleFunc("/apis/v1beta1/pipelines/upload/resumable/", s.dispatchTusUpload)
+	mux.HandleFunc("/apis/v1beta1/pipelines/", s.dispatchPipelineSubresource)
+}
+
+// dispatchTusUpload routes a request under
+// /apis/v1beta1/pipelines/upload/resumable/{uploadId} to HeadTusUpload or
+// PatchTusUpload, by the same hand-parsed-path-parameter convention as
+// dispatchPipelineSubresource.
+func (s *PipelineUploadServer) dispatchTusUpload(w http.ResponseWriter, r *http.Request) {
+	uploadID := strings.TrimPrefix(r.URL.Path, "/apis/v1beta1/pipelines/upload/resumable/")
+	if uploadID == "" {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodHead:
+		s.HeadTusUpload(w, r, uploadID)
+	case http.MethodPatch:
+		s.PatchTusUpload(w, r, uploadID)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// dispatchPipelineSubresource routes a request under
+// /apis/v1beta1/pipelines/{id}/... to the attestation/original-manifest/
+// component endpoints, which take their pipelineId (and, for components,
+// sha) path parameters parsed out by hand since this server predates any
+// generated path-templated router.
+func (s *PipelineUploadServer) dispatchPipelineSubresource(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/apis/v1beta1/pipelines/")
+	segments := strings.Split(rest, "/")
+	if len(segments) < 2 || segments[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	pipelineId := segments[0]
+	switch {
+	case len(segments) == 2 && segments[1] == "attestation":
+		s.GetPipelineAttestation(w, r, pipelineId)
+	case len(segments) == 2 && segments[1] == "original_manifest":
+		s.GetPipelineOriginalManifest(w, r, pipelineId)
+	case len(segments) == 3 && segments[1] == "components" && segments[2] != "":
+		s.GetPipelineComponent(w, r, pipelineId, segments[2])
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/kubeflow/pipelines/backend/src/apiserver/common"
+	"github.com/kubeflow/pipelines/backend/src/apiserver/converter"
+	"github.com/kubeflow/pipelines/backend/src/apiserver/resource"
+	"github.com/kubeflow/pipelines/backend/src/common/util"
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+const (
+	// TusResumableVersion is the tus.io protocol version this server
+	// implements, echoed on every tus response as required by the spec.
+	TusResumableVersion = "1.0.0"
+	// TusUploadTTL is how long an incomplete upload is kept before Sweep
+	// reclaims its storage.
+	TusUploadTTL = 24 * time.Hour
+	// TusSweepInterval is how often startTusSweeper calls UploadStore.Sweep to
+	// reclaim expired incomplete uploads.
+	TusSweepInterval = 1 * time.Hour
+
+	tusResumableHeader = "Tus-Resumable"
+	tusVersionHeader   = "Tus-Version"
+	uploadLengthHeader = "Upload-Length"
+	uploadOffsetHeader = "Upload-Offset"
+	uploadMetadataHdr  = "Upload-Metadata"
+)
+
+// CreateTusUpload handles `POST` to the tus creation endpoint: it reserves
+// storage for Upload-Length bytes and returns the new upload's URL in
+// `Location`.
+func (s *PipelineUploadServer) CreateTusUpload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(tusResumableHeader, TusResumableVersion)
+	if s.options.UploadStore == nil {
+		s.writeErrorToResponse(w, http.StatusNotImplemented, errors.New("Resumable (tus) pipeline upload is not configured on this server."))
+		return
+	}
+
+	totalSize, err := strconv.ParseInt(r.Header.Get(uploadLengthHeader), 10, 64)
+	if err != nil || totalSize <= 0 {
+		s.writeErrorToResponse(w, http.StatusBadRequest, errors.New("Upload-Length header must be a positive integer."))
+		return
+	}
+	if totalSize > MaxFileLength {
+		s.writeErrorToResponse(w, http.StatusRequestEntityTooLarge, errors.Errorf("Upload-Length %d exceeds the maximum pipeline file size of %d bytes.", totalSize, MaxFileLength))
+		return
+	}
+
+	metadata, err := parseTusMetadata(r.Header.Get(uploadMetadataHdr))
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Invalid Upload-Metadata header."))
+		return
+	}
+
+	id, err := s.options.UploadStore.CreateUpload(totalSize, metadata, TusUploadTTL)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Failed to create resumable upload."))
+		return
+	}
+
+	w.Header().Set("Location", strings.TrimRight(r.URL.String(), "/")+"/"+id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// startTusSweeper runs UploadStore.Sweep every TusSweepInterval for the rest
+// of the process's life (the ticker is never stopped), reclaiming incomplete
+// uploads whose TTL has passed. No-ops if no UploadStore is configured.
+func (s *PipelineUploadServer) startTusSweeper() {
+	if s.options.UploadStore == nil {
+		return
+	}
+	ticker := time.NewTicker(TusSweepInterval)
+	go func() {
+		for range ticker.C {
+			if err := s.options.UploadStore.Sweep(); err != nil {
+				glog.Errorf("Failed to sweep expired tus uploads: %+v", err)
+			}
+		}
+	}()
+}
+
+// HeadTusUpload handles `HEAD` to an upload's URL: it reports the current
+// offset so the client can resume from the right byte.
+func (s *PipelineUploadServer) HeadTusUpload(w http.ResponseWriter, r *http.Request, uploadID string) {
+	w.Header().Set(tusResumableHeader, TusResumableVersion)
+	if s.options.UploadStore == nil {
+		s.writeErrorToResponse(w, http.StatusNotImplemented, errors.New("Resumable (tus) pipeline upload is not configured on this server."))
+		return
+	}
+
+	info, err := s.options.UploadStore.Info(uploadID)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(info.Offset, 10))
+	w.Header().Set(uploadLengthHeader, strconv.FormatInt(info.TotalSize, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// PatchTusUpload handles `PATCH` to an upload's URL: it appends the request
+// body at Upload-Offset, and once the upload is complete runs it through the
+// same bundle-handling, signature-verification, format-conversion and
+// CreatePipeline path as the one-shot endpoint, so a namespace's verification
+// policy and non-native manifest support cannot be bypassed by uploading over
+// tus instead. A signature/certificate/bundle/attestation for the upload, if
+// any, is supplied via the matching base64-encoded Upload-Metadata key
+// (SignatureFileKey/CertificateFileKey/BundleFileKey/AttestationFileKey),
+// since tus has no multipart body to carry them in separately.
+func (s *PipelineUploadServer) PatchTusUpload(w http.ResponseWriter, r *http.Request, uploadID string) {
+	w.Header().Set(tusResumableHeader, TusResumableVersion)
+	if s.options.UploadStore == nil {
+		s.writeErrorToResponse(w, http.StatusNotImplemented, errors.New("Resumable (tus) pipeline upload is not configured on this server."))
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		s.writeErrorToResponse(w, http.StatusUnsupportedMediaType, errors.New("PATCH requires Content-Type: application/offset+octet-stream."))
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get(uploadOffsetHeader), 10, 64)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, errors.New("Upload-Offset header must be an integer."))
+		return
+	}
+
+	newOffset, err := s.options.UploadStore.WriteChunk(uploadID, offset, r.Body)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusConflict, err)
+		return
+	}
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(newOffset, 10))
+
+	info, err := s.options.UploadStore.Info(uploadID)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	if newOffset < info.TotalSize {
+		// Upload still incomplete; the client will PATCH again from newOffset.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	glog.Infof("Resumable upload %q complete, finalizing pipeline creation", uploadID)
+	reader, err := s.options.UploadStore.Open(uploadID)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Failed to open completed resumable upload."))
+		return
+	}
+	defer reader.Close()
+
+	filename := info.Metadata["filename"]
+
+	var rawFile []byte
+	var bundle *PipelineBundle
+	if IsBundleFilename(filename) {
+		bundle, err = ReadBundle(filename, reader)
+		if err != nil {
+			s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Error reading pipeline bundle."))
+			return
+		}
+		rawFile = bundle.PipelineSpec
+	} else {
+		rawFile, err = ReadPipelineFile(filename, reader, MaxFileLength)
+		if err != nil {
+			s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Error read pipeline file."))
+			return
+		}
+	}
+
+	namespaceQuery := r.URL.Query().Get(NamespaceStringQuery)
+	pipelineNamespace, err := GetPipelineNamespace(namespaceQuery)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Invalid pipeline namespace."))
+		return
+	}
+
+	// Signatures are produced over the file exactly as uploaded, so they must
+	// be checked against rawFile before any format conversion rewrites it.
+	signed := &resource.SignedPipelineFile{
+		Signature:   []byte(info.Metadata[SignatureFileKey]),
+		Certificate: []byte(info.Metadata[CertificateFileKey]),
+		Bundle:      []byte(info.Metadata[BundleFileKey]),
+		Attestation: []byte(info.Metadata[AttestationFileKey]),
+	}
+	attestation, err := s.verifySignedPipelineFile(pipelineNamespace, rawFile, signed)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusForbidden, err)
+		return
+	}
+
+	pipelineFile := rawFile
+	var originalManifest []byte
+	if !IsBundleFilename(filename) {
+		var converted *converter.ConversionResult
+		converted, err = s.convertPipelineFileFormat(w, r, rawFile)
+		if err != nil {
+			s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Error converting pipeline file."))
+			return
+		}
+		pipelineFile = converted.PipelineSpec
+		if converted.DetectedFormat != converter.SourceFormatKFP {
+			originalManifest = converted.OriginalManifest
+		}
+	}
+
+	resourceAttributes := &authorizationv1.ResourceAttributes{
+		Namespace: pipelineNamespace,
+		Verb:      common.RbacResourceVerbCreate,
+	}
+	if err = s.canUploadVersionedPipeline(r, "", resourceAttributes); err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Authorization to namespace failed."))
+		return
+	}
+
+	pipelineName := info.Metadata["name"]
+	if pipelineName == "" {
+		pipelineName, err = GetPipelineName("", filename)
+		if err != nil {
+			s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Invalid pipeline name."))
+			return
+		}
+	}
+
+	if bundle != nil && len(bundle.SubArtifacts) > 0 && s.options.ComponentStore == nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, errors.New("Pipeline bundle uploaded but no component store is configured."))
+		return
+	}
+	if err = s.validatePipelineFile(pipelineFile, pipelineNamespace); err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	newPipeline, err := s.resourceManager.CreatePipeline(pipelineName, info.Metadata["description"], pipelineNamespace, pipelineFile)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Error creating pipeline"))
+		return
+	}
+	s.recordAttestation(newPipeline.UUID, attestation)
+	s.recordOriginalManifest(newPipeline.UUID, originalManifest)
+
+	if bundle != nil && len(bundle.SubArtifacts) > 0 {
+		if err = s.options.ComponentStore.PutComponents(newPipeline.UUID, bundle.SubArtifacts); err != nil {
+			s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Error storing pipeline bundle components"))
+			return
+		}
+	}
+
+	if err = s.options.UploadStore.Delete(uploadID); err != nil {
+		glog.Warningf("Failed to delete completed resumable upload %q: %v", uploadID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	marshaler := &jsonpb.Marshaler{EnumsAsInts: false, OrigName: true}
+	if err = marshaler.Marshal(w, ToApiPipeline(newPipeline)); err != nil {
+		s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Error creating pipeline"))
+		return
+	}
+}
+
+// parseTusMetadata decodes a tus `Upload-Metadata` header: a comma-separated
+// list of "key base64(value)" pairs.
+func parseTusMetadata(header string) (UploadMetadata, error) {
+	metadata := UploadMetadata{}
+	if header == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), " ", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("malformed Upload-Metadata entry %q", pair)
+		}
+		value, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "malformed base64 value for Upload-Metadata key %q", parts[0])
+		}
+		metadata[parts[0]] = string(value)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,218 @@
+// Copyright 2018 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// UploadMetadata is the parsed key/value pairs from a tus `Upload-Metadata`
+// header, most notably "filename" and "name" (the pipeline name override).
+type UploadMetadata map[string]string
+
+// UploadInfo describes an in-progress or completed tus upload.
+type UploadInfo struct {
+	ID        string
+	TotalSize int64
+	Offset    int64
+	Metadata  UploadMetadata
+	ExpiresAt time.Time
+}
+
+// UploadStore is the pluggable backend behind the tus.io endpoint set.
+// PipelineUploadServer does not serialize requests for the same upload ID, so
+// implementations must be safe for concurrent calls, including concurrent
+// WriteChunk calls to the same ID.
+type UploadStore interface {
+	// CreateUpload reserves storage for a new upload of totalSize bytes,
+	// returning a newly generated upload ID.
+	CreateUpload(totalSize int64, metadata UploadMetadata, ttl time.Duration) (string, error)
+	// Info returns the current state of upload id, or an error if it does not
+	// exist or has expired.
+	Info(id string) (*UploadInfo, error)
+	// WriteChunk appends data to upload id starting at offset, returning the
+	// new total offset. It is an error for offset to not match the upload's
+	// current offset (the client must resume from where the server left off).
+	WriteChunk(id string, offset int64, data io.Reader) (int64, error)
+	// Open returns a reader over the complete upload, once Info(id).Offset ==
+	// Info(id).TotalSize.
+	Open(id string) (io.ReadCloser, error)
+	// Delete removes the upload's storage, called once it has been consumed by
+	// CreatePipeline or after its TTL expires.
+	Delete(id string) error
+	// Sweep deletes any uploads whose TTL has passed. Called periodically by
+	// the server; implementations that don't need it may no-op.
+	Sweep() error
+}
+
+// FilesystemUploadStore is an UploadStore backed by a directory on local (or
+// networked, e.g. NFS-backed) disk. Each upload is a single file named by its
+// ID; UploadInfo bookkeeping is kept in memory, so upload state does not
+// survive a server restart (an S3/MinIO-backed UploadStore that persists
+// UploadInfo as object metadata would not have this limitation).
+type FilesystemUploadStore struct {
+	baseDir string
+
+	mu   sync.Mutex
+	info map[string]*UploadInfo
+}
+
+// NewFilesystemUploadStore constructs a FilesystemUploadStore rooted at
+// baseDir, creating it if necessary.
+func NewFilesystemUploadStore(baseDir string) (*FilesystemUploadStore, error) {
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "Failed to create tus upload directory %q", baseDir)
+	}
+	return &FilesystemUploadStore{baseDir: baseDir, info: make(map[string]*UploadInfo)}, nil
+}
+
+func (s *FilesystemUploadStore) path(id string) string {
+	return filepath.Join(s.baseDir, id)
+}
+
+func (s *FilesystemUploadStore) CreateUpload(totalSize int64, metadata UploadMetadata, ttl time.Duration) (string, error) {
+	id := generateUploadID()
+
+	f, err := os.Create(s.path(id))
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to create tus upload file")
+	}
+	f.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info[id] = &UploadInfo{
+		ID:        id,
+		TotalSize: totalSize,
+		Offset:    0,
+		Metadata:  metadata,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	return id, nil
+}
+
+func (s *FilesystemUploadStore) Info(id string) (*UploadInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.info[id]
+	if !ok {
+		return nil, errors.Errorf("Unknown or expired upload %q", id)
+	}
+	if time.Now().After(info.ExpiresAt) {
+		return nil, errors.Errorf("Upload %q has expired", id)
+	}
+	copied := *info
+	return &copied, nil
+}
+
+func (s *FilesystemUploadStore) WriteChunk(id string, offset int64, data io.Reader) (int64, error) {
+	// The lock is held for the whole write, so two PATCHes to the same upload
+	// cannot both pass the offset check and write over each other.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.info[id]
+	if !ok {
+		return 0, errors.Errorf("Unknown or expired upload %q", id)
+	}
+	if offset != info.Offset {
+		return 0, errors.Errorf("Upload-Offset %d does not match the server's current offset %d for upload %q", offset, info.Offset, id)
+	}
+
+	f, err := os.OpenFile(s.path(id), os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to open tus upload file")
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, errors.Wrap(err, "Failed to seek tus upload file")
+	}
+
+	// A PATCH body is bounded to what's left of the declared Upload-Length
+	// (info.TotalSize - offset), read one byte past that limit so that a
+	// client which sends more than it declared is rejected outright rather
+	// than silently truncated to the declared size.
+	remaining := info.TotalSize - offset
+	written, err := io.Copy(f, io.LimitReader(data, remaining+1))
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to write tus upload chunk")
+	}
+	if written > remaining {
+		f.Truncate(offset + remaining)
+		return 0, errors.Errorf("PATCH body exceeds the declared Upload-Length for upload %q", id)
+	}
+
+	info.Offset += written
+	return info.Offset, nil
+}
+
+func (s *FilesystemUploadStore) Open(id string) (io.ReadCloser, error) {
+	info, err := s.Info(id)
+	if err != nil {
+		return nil, err
+	}
+	if info.Offset != info.TotalSize {
+		return nil, errors.Errorf("Upload %q is not yet complete (%d/%d bytes)", id, info.Offset, info.TotalSize)
+	}
+	return os.Open(s.path(id))
+}
+
+func (s *FilesystemUploadStore) Delete(id string) error {
+	s.mu.Lock()
+	delete(s.info, id)
+	s.mu.Unlock()
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "Failed to delete tus upload file %q", id)
+	}
+	return nil
+}
+
+func (s *FilesystemUploadStore) Sweep() error {
+	s.mu.Lock()
+	var expired []string
+	now := time.Now()
+	for id, info := range s.info {
+		if now.After(info.ExpiresAt) {
+			expired = append(expired, id)
+		}
+	}
+	s.mu.Unlock()
+
+	var firstErr error
+	for _, id := range expired {
+		if err := s.Delete(id); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// generateUploadID returns a random hex-encoded upload ID.
+func generateUploadID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand.Read only fails if the OS source is broken; fall back to
+		// a timestamp so upload creation still succeeds.
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(buf)
+}
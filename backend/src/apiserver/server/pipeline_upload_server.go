@@ -16,21 +16,27 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 
-	"google.golang.org/grpc/metadata"
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/jsonpb"
 	api "github.com/kubeflow/pipelines/backend/api/v1beta1/go_client"
 	"github.com/kubeflow/pipelines/backend/src/apiserver/common"
+	"github.com/kubeflow/pipelines/backend/src/apiserver/converter"
+	_ "github.com/kubeflow/pipelines/backend/src/apiserver/converter/argo"
+	_ "github.com/kubeflow/pipelines/backend/src/apiserver/converter/tekton"
+	"github.com/kubeflow/pipelines/backend/src/apiserver/pipeline"
 	"github.com/kubeflow/pipelines/backend/src/apiserver/resource"
 	"github.com/kubeflow/pipelines/backend/src/common/util"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/metadata"
 	authorizationv1 "k8s.io/api/authorization/v1"
 )
 
@@ -42,6 +48,42 @@ const (
 	NamespaceStringQuery      = "namespace"
 	// Pipeline Id in the query string specifies a pipeline when creating versions.
 	PipelineKey = "pipelineid"
+	// SignatureFileKey is the optional multipart part carrying a detached
+	// signature (or Sigstore bundle) over the uploaded pipeline file.
+	SignatureFileKey = "signature"
+	// CertificateFileKey is the optional multipart part carrying the signer's
+	// certificate, used together with SignatureFileKey for keyless verification.
+	CertificateFileKey = "certificate"
+	// BundleFileKey is the optional multipart part carrying a single Sigstore
+	// bundle (signature + certificate + Rekor entry), as an alternative to
+	// SignatureFileKey/CertificateFileKey.
+	BundleFileKey = "bundle"
+	// AttestationFileKey is the optional multipart part carrying a
+	// DSSE-enveloped in-toto attestation over the uploaded pipeline file.
+	AttestationFileKey = "attestation"
+	// SourceFormatQueryStringKey selects which authoring format the uploaded
+	// manifest is in: "kfp", "argo", "tekton", or "auto" (the default) to
+	// detect it from the manifest contents.
+	SourceFormatQueryStringKey = "sourceFormat"
+	// DetectedSourceFormatHeader reports the source format that was detected
+	// (or requested) for the uploaded manifest.
+	DetectedSourceFormatHeader = "X-Pipeline-Source-Format"
+	// ConversionWarningsHeader carries non-fatal warnings produced while
+	// converting a non-native manifest into the KFP pipeline spec, one
+	// JSON-encoded string array.
+	ConversionWarningsHeader = "X-Pipeline-Conversion-Warnings"
+	// ResolvedOCIDigestHeader reports the manifest digest an OCI reference
+	// resolved to, for UploadPipelineFromOCI. Distinct from
+	// DetectedSourceFormatHeader, which carries a format name ("argo"/"tekton"/
+	// "kfp"), not a digest.
+	ResolvedOCIDigestHeader = "X-Pipeline-OCI-Resolved-Digest"
+	// DryRunQueryStringKey runs the full parse/validate/RBAC pipeline, plus a
+	// name/namespace collision check when PipelineUploadServerOptions.NameLookup
+	// is configured, without persisting anything, returning a
+	// pipeline.ValidationReport.
+	DryRunQueryStringKey = "dryRun"
+	// ValidateOnlyQueryStringKey is an alias for DryRunQueryStringKey.
+	ValidateOnlyQueryStringKey = "validateOnly"
 )
 
 // Metric variables. Please prefix the metric names with pipeline_upload_ or pipeline_version_upload_.
@@ -56,11 +98,111 @@ var (
 		Help: "The number of pipeline version upload requests",
 	})
 
+	pipelineUploadSignatureVerifiedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_upload_signature_verified_total",
+		Help: "The number of pipeline upload signature verification attempts, by result",
+	}, []string{"result"})
+
 	// TODO(jingzhang36): error count and success count.
 )
 
 type PipelineUploadServerOptions struct {
 	CollectMetrics bool
+	// PipelineVerifier verifies detached signatures/in-toto attestations carried
+	// with an upload. May be nil, in which case signature verification is
+	// skipped for every namespace regardless of policy.
+	PipelineVerifier *resource.PipelineVerifierManager
+	// AttestationStore persists the VerifiedAttestation produced by
+	// PipelineVerifier so it can be re-exposed via GetPipelineAttestation. May
+	// be nil, in which case attestations are verified but not retained.
+	AttestationStore PipelineAttestationStore
+	// ManifestStore persists the original, pre-conversion manifest of a
+	// pipeline uploaded in a non-native (Argo/Tekton) format, so it can be
+	// re-served via GetPipelineOriginalManifest. May be nil, in which case the
+	// original manifest is discarded once converted.
+	ManifestStore PipelineManifestStore
+	// OCIClient pulls/pushes pipeline artifacts from/to OCI registries for
+	// UploadPipelineFromOCI and PushPipelineToOCI. May be nil, in which case
+	// both endpoints respond with 501 Not Implemented.
+	OCIClient *resource.PipelineOCIClient
+	// ComponentStore persists bundle sub-artifacts so GetPipelineComponent can
+	// serve them back. May be nil, in which case bundle uploads that carry any
+	// components/ or resources/ files are rejected.
+	ComponentStore PipelineComponentStore
+	// UploadStore backs the tus.io resumable upload endpoint set. May be nil,
+	// in which case those endpoints respond with 501 Not Implemented; the
+	// one-shot multipart endpoint keeps working either way.
+	UploadStore UploadStore
+	// Validator runs lint-style checks shared by the dryRun/validateOnly query
+	// parameter and the real create path (see validatePipelineFile).
+	Validator *pipeline.Validator
+	// NameLookup backs the dry-run path's name/namespace collision check. May
+	// be nil, in which case that check is skipped -- a Validator Rule cannot
+	// perform it itself, since Rule.Check only ever sees a parsed Document,
+	// not a live resourceManager (see namespaceQuotaRule's doc comment for the
+	// same constraint).
+	NameLookup PipelineNameLookup
+}
+
+// PipelineNameLookup resolves whether a pipeline or pipeline version name is
+// already taken, so the dry-run path can report a collision before the real
+// create path would reject it with a uniqueness-constraint error.
+type PipelineNameLookup interface {
+	// PipelineExists reports whether a pipeline named name already exists in
+	// namespace.
+	PipelineExists(name, namespace string) (bool, error)
+	// PipelineVersionExists reports whether pipelineId already has a version
+	// named name.
+	PipelineVersionExists(pipelineId, name string) (bool, error)
+}
+
+// ociUploadRequest is the JSON body of a POST to UploadPipelineFromOCI.
+type ociUploadRequest struct {
+	// OCIRef is the OCI reference to pull the pipeline artifact from, e.g.
+	// "registry/ns/pipeline@sha256:...".
+	OCIRef string `json:"ociRef"`
+	// Signature, Certificate, Bundle and Attestation are optional
+	// base64-encoded detached-signature material verified against the pulled
+	// pipeline spec, mirroring the multipart upload's
+	// signature/certificate/bundle/attestation parts -- an OCI reference has
+	// no multipart body to carry them in separately.
+	Signature   string `json:"signature,omitempty"`
+	Certificate string `json:"certificate,omitempty"`
+	Bundle      string `json:"bundle,omitempty"`
+	Attestation string `json:"attestation,omitempty"`
+}
+
+// signedPipelineFileFromOCIRequest decodes the optional base64-encoded
+// signature material carried on an ociUploadRequest.
+func signedPipelineFileFromOCIRequest(req *ociUploadRequest) (*resource.SignedPipelineFile, error) {
+	sig, err := base64.StdEncoding.DecodeString(req.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "signature is not valid base64")
+	}
+	cert, err := base64.StdEncoding.DecodeString(req.Certificate)
+	if err != nil {
+		return nil, errors.Wrap(err, "certificate is not valid base64")
+	}
+	bundle, err := base64.StdEncoding.DecodeString(req.Bundle)
+	if err != nil {
+		return nil, errors.Wrap(err, "bundle is not valid base64")
+	}
+	attestation, err := base64.StdEncoding.DecodeString(req.Attestation)
+	if err != nil {
+		return nil, errors.Wrap(err, "attestation is not valid base64")
+	}
+	return &resource.SignedPipelineFile{
+		Signature:   sig,
+		Certificate: cert,
+		Bundle:      bundle,
+		Attestation: attestation,
+	}, nil
+}
+
+// ociPushRequest is the JSON body of a POST to PushPipelineToOCI.
+type ociPushRequest struct {
+	// OCIRef is the OCI reference to push the packaged pipeline artifact to.
+	OCIRef string `json:"ociRef"`
 }
 
 type PipelineUploadServer struct {
@@ -87,10 +229,21 @@ func (s *PipelineUploadServer) UploadPipeline(w http.ResponseWriter, r *http.Req
 	}
 	defer file.Close()
 
-	pipelineFile, err := ReadPipelineFile(header.Filename, file, MaxFileLength)
-	if err != nil {
-		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Error read pipeline file."))
-		return
+	var rawFile []byte
+	var bundle *PipelineBundle
+	if IsBundleFilename(header.Filename) {
+		bundle, err = ReadBundle(header.Filename, file)
+		if err != nil {
+			s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Error reading pipeline bundle."))
+			return
+		}
+		rawFile = bundle.PipelineSpec
+	} else {
+		rawFile, err = ReadPipelineFile(header.Filename, file, MaxFileLength)
+		if err != nil {
+			s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Error read pipeline file."))
+			return
+		}
 	}
 
 	namespaceQuery := r.URL.Query().Get(NamespaceStringQuery)
@@ -100,9 +253,32 @@ func (s *PipelineUploadServer) UploadPipeline(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// Signatures are produced over the file exactly as uploaded, so they must
+	// be checked against rawFile before any format conversion rewrites it.
+	attestation, err := s.verifyPipelineSignature(r, pipelineNamespace, rawFile)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusForbidden, err)
+		return
+	}
+
+	pipelineFile := rawFile
+	var originalManifest []byte
+	if !IsBundleFilename(header.Filename) {
+		var converted *converter.ConversionResult
+		converted, err = s.convertPipelineFileFormat(w, r, rawFile)
+		if err != nil {
+			s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Error converting pipeline file."))
+			return
+		}
+		pipelineFile = converted.PipelineSpec
+		if converted.DetectedFormat != converter.SourceFormatKFP {
+			originalManifest = converted.OriginalManifest
+		}
+	}
+
 	resourceAttributes := &authorizationv1.ResourceAttributes{
 		Namespace: pipelineNamespace,
-		Verb: common.RbacResourceVerbCreate,
+		Verb:      common.RbacResourceVerbCreate,
 	}
 	err = s.canUploadVersionedPipeline(r, "", resourceAttributes)
 	if err != nil {
@@ -121,11 +297,38 @@ func (s *PipelineUploadServer) UploadPipeline(w http.ResponseWriter, r *http.Req
 		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Error read pipeline description."))
 		return
 	}
+	if isDryRun(r) {
+		s.writeValidationReport(w, pipelineFile, pipelineNamespace, s.pipelineNameCollisionDiagnostic(pipelineName, pipelineNamespace))
+		return
+	}
+
+	// Check that a bundle upload can actually be persisted before creating the
+	// pipeline row, so a server with no ComponentStore configured rejects the
+	// upload outright instead of leaving an orphaned, componentless pipeline
+	// behind.
+	if bundle != nil && len(bundle.SubArtifacts) > 0 && s.options.ComponentStore == nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, errors.New("Pipeline bundle uploaded but no component store is configured."))
+		return
+	}
+	if err = s.validatePipelineFile(pipelineFile, pipelineNamespace); err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
 	newPipeline, err := s.resourceManager.CreatePipeline(pipelineName, pipelineDescription, pipelineNamespace, pipelineFile)
 	if err != nil {
 		s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Error creating pipeline"))
 		return
 	}
+	s.recordAttestation(newPipeline.UUID, attestation)
+	s.recordOriginalManifest(newPipeline.UUID, originalManifest)
+
+	if bundle != nil && len(bundle.SubArtifacts) > 0 {
+		if err = s.options.ComponentStore.PutComponents(newPipeline.UUID, bundle.SubArtifacts); err != nil {
+			s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Error storing pipeline bundle components"))
+			return
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	marshaler := &jsonpb.Marshaler{EnumsAsInts: false, OrigName: true}
@@ -136,6 +339,161 @@ func (s *PipelineUploadServer) UploadPipeline(w http.ResponseWriter, r *http.Req
 	}
 }
 
+// HTTP JSON endpoint for creating a pipeline from an artifact already
+// published to an OCI registry, as an alternative to a multipart file upload.
+// The request body is {"ociRef": "registry/ns/pipeline@sha256:..."}.
+func (s *PipelineUploadServer) UploadPipelineFromOCI(w http.ResponseWriter, r *http.Request) {
+	if s.options.CollectMetrics {
+		uploadPipelineRequests.Inc()
+	}
+
+	glog.Infof("Upload pipeline from OCI called")
+	if s.options.OCIClient == nil {
+		s.writeErrorToResponse(w, http.StatusNotImplemented, errors.New("OCI-backed pipeline upload is not configured on this server."))
+		return
+	}
+
+	var req ociUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OCIRef == "" {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.NewInvalidInputError("Request body must be JSON of the form {\"ociRef\": \"registry/ns/pipeline@sha256:...\"}."))
+		return
+	}
+
+	namespaceQuery := r.URL.Query().Get(NamespaceStringQuery)
+	pipelineNamespace, err := GetPipelineNamespace(namespaceQuery)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Invalid pipeline namespace."))
+		return
+	}
+
+	resourceAttributes := &authorizationv1.ResourceAttributes{
+		Namespace: pipelineNamespace,
+		Verb:      common.RbacResourceVerbCreate,
+	}
+	if err = s.canUploadVersionedPipeline(r, "", resourceAttributes); err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Authorization to namespace failed."))
+		return
+	}
+
+	pulled, err := s.options.OCIClient.PullPipeline(r.Context(), pipelineNamespace, req.OCIRef)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Failed to pull pipeline from OCI registry"))
+		return
+	}
+
+	// The pulled artifact must pass the same namespace signature-verification
+	// policy and shared Validator as the multipart and tus upload paths, or a
+	// namespace with VerificationPolicyRequired could be bypassed by pulling
+	// an unsigned pipeline from an OCI registry instead of uploading it directly.
+	signed, err := signedPipelineFileFromOCIRequest(&req)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Invalid signature material."))
+		return
+	}
+	attestation, err := s.verifySignedPipelineFile(pipelineNamespace, pulled.PipelineSpec, signed)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusForbidden, err)
+		return
+	}
+	if err = s.validatePipelineFile(pulled.PipelineSpec, pipelineNamespace); err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	fileNameQueryString := r.URL.Query().Get(NameQueryStringKey)
+	pipelineName, err := GetPipelineName(fileNameQueryString, req.OCIRef)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Invalid pipeline name."))
+		return
+	}
+	pipelineDescription, err := url.QueryUnescape(r.URL.Query().Get(DescriptionQueryStringKey))
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Error read pipeline description."))
+		return
+	}
+
+	newPipeline, err := s.resourceManager.CreatePipeline(pipelineName, pipelineDescription, pipelineNamespace, pulled.PipelineSpec)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Error creating pipeline"))
+		return
+	}
+	s.recordAttestation(newPipeline.UUID, attestation)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(ResolvedOCIDigestHeader, pulled.ResolvedDigest)
+	marshaler := &jsonpb.Marshaler{EnumsAsInts: false, OrigName: true}
+	if err = marshaler.Marshal(w, ToApiPipeline(newPipeline)); err != nil {
+		s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Error creating pipeline"))
+		return
+	}
+}
+
+// HTTP JSON endpoint that packages a stored pipeline as an OCI artifact and
+// pushes it to a user-specified reference. The request body is
+// {"ociRef": "registry/ns/pipeline:tag"}.
+func (s *PipelineUploadServer) PushPipelineToOCI(w http.ResponseWriter, r *http.Request) {
+	if s.options.OCIClient == nil {
+		s.writeErrorToResponse(w, http.StatusNotImplemented, errors.New("OCI-backed pipeline push is not configured on this server."))
+		return
+	}
+
+	pipelineId := r.URL.Query().Get(PipelineKey)
+	if len(pipelineId) == 0 {
+		s.writeErrorToResponse(w, http.StatusBadRequest, errors.New("Please specify a pipeline id to push."))
+		return
+	}
+
+	var req ociPushRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OCIRef == "" {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.NewInvalidInputError("Request body must be JSON of the form {\"ociRef\": \"registry/ns/pipeline:tag\"}."))
+		return
+	}
+
+	namespace, err := s.resourceManager.GetNamespaceFromPipelineID(pipelineId)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Failed to get namespace from pipelineId."))
+		return
+	}
+	// Pushing ships the pipeline's full contents to a caller-specified,
+	// external ociRef, so this requires the same write-level verb as creating
+	// a pipeline, not RbacResourceVerbGet: a user who can only read a pipeline
+	// must not be able to exfiltrate it to a registry of their choosing.
+	// Operators running in multi-user mode should also set a non-empty
+	// PipelineOCIOptions.AllowedRegistries, since an unset allowlist permits
+	// pushing to any registry.
+	resourceAttributes := &authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      common.RbacResourceVerbCreate,
+	}
+	if err = s.canUploadVersionedPipeline(r, pipelineId, resourceAttributes); err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Authorization to namespace failed."))
+		return
+	}
+
+	pipeline, err := s.resourceManager.GetPipeline(pipelineId)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Error fetching pipeline to push"))
+		return
+	}
+	pipelineSpec, err := s.resourceManager.GetPipelineTemplate(pipelineId)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Error fetching pipeline spec to push"))
+		return
+	}
+
+	if err = s.options.OCIClient.PushPipeline(r.Context(), namespace, req.OCIRef, pipelineSpec); err != nil {
+		s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Failed to push pipeline to OCI registry"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	marshaler := &jsonpb.Marshaler{EnumsAsInts: false, OrigName: true}
+	if err = marshaler.Marshal(w, ToApiPipeline(pipeline)); err != nil {
+		s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Error marshaling pushed pipeline"))
+		return
+	}
+}
+
 // HTTP multipart endpoint for uploading pipeline version file.
 // https://www.w3.org/Protocols/rfc1341/7_2_Multipart.html
 // This endpoint is not exposed through grpc endpoint, since grpc-gateway can't convert the gRPC
@@ -155,7 +513,7 @@ func (s *PipelineUploadServer) UploadPipelineVersion(w http.ResponseWriter, r *h
 	}
 	defer file.Close()
 
-	pipelineFile, err := ReadPipelineFile(header.Filename, file, MaxFileLength)
+	rawFile, err := ReadPipelineFile(header.Filename, file, MaxFileLength)
 	if err != nil {
 		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Error read pipeline version file."))
 		return
@@ -183,6 +541,25 @@ func (s *PipelineUploadServer) UploadPipelineVersion(w http.ResponseWriter, r *h
 		return
 	}
 
+	// Signatures are produced over the file exactly as uploaded, so they must
+	// be checked against rawFile before any format conversion rewrites it.
+	attestation, err := s.verifyPipelineSignature(r, namespace, rawFile)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusForbidden, err)
+		return
+	}
+
+	converted, err := s.convertPipelineFileFormat(w, r, rawFile)
+	if err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, util.Wrap(err, "Error converting pipeline version file."))
+		return
+	}
+	pipelineFile := converted.PipelineSpec
+	var originalManifest []byte
+	if converted.DetectedFormat != converter.SourceFormatKFP {
+		originalManifest = converted.OriginalManifest
+	}
+
 	resourceAttributes := &authorizationv1.ResourceAttributes{
 		Namespace: namespace,
 		Verb:      common.RbacResourceVerbCreate,
@@ -193,6 +570,16 @@ func (s *PipelineUploadServer) UploadPipelineVersion(w http.ResponseWriter, r *h
 		return
 	}
 
+	if isDryRun(r) {
+		s.writeValidationReport(w, pipelineFile, namespace, s.pipelineVersionNameCollisionDiagnostic(pipelineId, pipelineVersionName))
+		return
+	}
+
+	if err = s.validatePipelineFile(pipelineFile, namespace); err != nil {
+		s.writeErrorToResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
 	newPipelineVersion, err := s.resourceManager.CreatePipelineVersion(
 		&api.PipelineVersion{
 			Name:        pipelineVersionName,
@@ -211,6 +598,8 @@ func (s *PipelineUploadServer) UploadPipelineVersion(w http.ResponseWriter, r *h
 		s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Error creating pipeline version"))
 		return
 	}
+	s.recordAttestation(newPipelineVersion.UUID, attestation)
+	s.recordOriginalManifest(newPipelineVersion.UUID, originalManifest)
 
 	w.Header().Set("Content-Type", "application/json")
 	marshaler := &jsonpb.Marshaler{EnumsAsInts: false, OrigName: true}
@@ -230,6 +619,186 @@ func (s *PipelineUploadServer) UploadPipelineVersion(w http.ResponseWriter, r *h
 	}
 }
 
+// isDryRun reports whether the request asked to validate-only, via either the
+// dryRun or validateOnly query parameter.
+func isDryRun(r *http.Request) bool {
+	query := r.URL.Query()
+	return query.Get(DryRunQueryStringKey) == "true" || query.Get(ValidateOnlyQueryStringKey) == "true"
+}
+
+// validatePipelineFile runs s.options.Validator (if configured) over
+// pipelineFile and rejects it if validation reports any error-severity
+// diagnostic. Called from the real create path with the same Validator the
+// dryRun/validateOnly query parameter uses, so the two cannot drift apart.
+func (s *PipelineUploadServer) validatePipelineFile(pipelineFile []byte, namespace string) error {
+	if s.options.Validator == nil {
+		return nil
+	}
+	report := s.options.Validator.Validate(pipelineFile, namespace)
+	if report.WouldSucceed {
+		return nil
+	}
+	return errors.Errorf("Pipeline spec failed validation: %+v", report.Diagnostics)
+}
+
+// pipelineNameCollisionDiagnostic checks, via s.options.NameLookup, whether
+// name already exists in namespace, returning a Diagnostic describing the
+// collision, or nil if there is none (or NameLookup is not configured, or
+// the lookup itself fails -- a lookup error should not block an otherwise
+// unrelated dry-run report).
+func (s *PipelineUploadServer) pipelineNameCollisionDiagnostic(name, namespace string) *pipeline.Diagnostic {
+	if s.options.NameLookup == nil {
+		return nil
+	}
+	exists, err := s.options.NameLookup.PipelineExists(name, namespace)
+	if err != nil {
+		glog.Errorf("Failed to check pipeline name collision for %q/%q: %+v", namespace, name, err)
+		return nil
+	}
+	if !exists {
+		return nil
+	}
+	return &pipeline.Diagnostic{
+		Severity: pipeline.SeverityError,
+		Code:     "name-namespace-collision",
+		Path:     "/pipelineInfo/name",
+		Message:  fmt.Sprintf("A pipeline named %q already exists in namespace %q", name, namespace),
+	}
+}
+
+// pipelineVersionNameCollisionDiagnostic is the PipelineVersion analogue of
+// pipelineNameCollisionDiagnostic: it checks whether pipelineId already has a
+// version named name.
+func (s *PipelineUploadServer) pipelineVersionNameCollisionDiagnostic(pipelineId, name string) *pipeline.Diagnostic {
+	if s.options.NameLookup == nil {
+		return nil
+	}
+	exists, err := s.options.NameLookup.PipelineVersionExists(pipelineId, name)
+	if err != nil {
+		glog.Errorf("Failed to check pipeline version name collision for %q/%q: %+v", pipelineId, name, err)
+		return nil
+	}
+	if !exists {
+		return nil
+	}
+	return &pipeline.Diagnostic{
+		Severity: pipeline.SeverityError,
+		Code:     "name-namespace-collision",
+		Path:     "/pipelineInfo/name",
+		Message:  fmt.Sprintf("Pipeline %q already has a version named %q", pipelineId, name),
+	}
+}
+
+// writeValidationReport runs s.options.Validator over pipelineFile for
+// namespace and writes the resulting pipeline.ValidationReport as the HTTP
+// response, in place of persisting the pipeline. Any collisionDiagnostics
+// (see pipelineNameCollisionDiagnostic) are merged in, since a name/namespace
+// collision is detected against resourceManager's persisted state rather
+// than anything a pure pipeline.Rule can inspect.
+func (s *PipelineUploadServer) writeValidationReport(w http.ResponseWriter, pipelineFile []byte, namespace string, collisionDiagnostics ...*pipeline.Diagnostic) {
+	if s.options.Validator == nil {
+		s.writeErrorToResponse(w, http.StatusNotImplemented, errors.New("Dry-run validation is not configured on this server."))
+		return
+	}
+	report := s.options.Validator.Validate(pipelineFile, namespace)
+	for _, d := range collisionDiagnostics {
+		if d == nil {
+			continue
+		}
+		report.Diagnostics = append(report.Diagnostics, *d)
+		if d.Severity == pipeline.SeverityError {
+			report.WouldSucceed = false
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		s.writeErrorToResponse(w, http.StatusInternalServerError, util.Wrap(err, "Error encoding validation report"))
+	}
+}
+
+// convertPipelineFileFormat detects (or honors the requested) source format of
+// pipelineFile and, for non-native formats, converts it into the KFP internal
+// pipeline spec. The detected format and any conversion warnings are reported
+// back to the caller via response headers, since the native format has no
+// field to carry them. The returned ConversionResult retains the original,
+// pre-conversion manifest bytes alongside the converted spec.
+func (s *PipelineUploadServer) convertPipelineFileFormat(w http.ResponseWriter, r *http.Request, pipelineFile []byte) (*converter.ConversionResult, error) {
+	requested := converter.SourceFormat(r.URL.Query().Get(SourceFormatQueryStringKey))
+	if requested == "" {
+		requested = converter.SourceFormatAuto
+	}
+
+	result, err := converter.Convert(requested, pipelineFile)
+	if err != nil {
+		return nil, err
+	}
+
+	w.Header().Set(DetectedSourceFormatHeader, string(result.DetectedFormat))
+	if len(result.Warnings) > 0 {
+		warningsJSON, err := json.Marshal(result.Warnings)
+		if err == nil {
+			w.Header().Set(ConversionWarningsHeader, string(warningsJSON))
+		}
+		for _, warning := range result.Warnings {
+			glog.Warningf("Pipeline conversion warning (%s): %s", result.DetectedFormat, warning)
+		}
+	}
+	return result, nil
+}
+
+// verifyPipelineSignature reads any of the optional
+// signature/certificate/bundle/attestation multipart parts, and enforces the
+// verification policy configured for namespace. It returns the resulting
+// attestation (nil if verification was not required), or an error if policy
+// requires a valid signature and verification failed.
+func (s *PipelineUploadServer) verifyPipelineSignature(r *http.Request, namespace string, pipelineFile []byte) (*resource.VerifiedAttestation, error) {
+	signed := &resource.SignedPipelineFile{}
+	signed.Signature = readOptionalFormFile(r, SignatureFileKey)
+	signed.Certificate = readOptionalFormFile(r, CertificateFileKey)
+	signed.Bundle = readOptionalFormFile(r, BundleFileKey)
+	signed.Attestation = readOptionalFormFile(r, AttestationFileKey)
+	return s.verifySignedPipelineFile(namespace, pipelineFile, signed)
+}
+
+// verifySignedPipelineFile enforces the verification policy configured for
+// namespace against an already-assembled SignedPipelineFile, shared by every
+// upload path (one-shot multipart, OCI and tus) so none of them can bypass the
+// policy the others honor. It returns the resulting attestation (nil if
+// verification was not required), or an error if policy requires a valid
+// signature and verification failed.
+func (s *PipelineUploadServer) verifySignedPipelineFile(namespace string, pipelineFile []byte, signed *resource.SignedPipelineFile) (*resource.VerifiedAttestation, error) {
+	if s.options.PipelineVerifier == nil {
+		return nil, nil
+	}
+
+	attestation, err := s.options.PipelineVerifier.VerifyForNamespace(namespace, pipelineFile, signed)
+	if err != nil {
+		if s.options.CollectMetrics {
+			pipelineUploadSignatureVerifiedTotal.WithLabelValues("failure").Inc()
+		}
+		return nil, err
+	}
+	if s.options.CollectMetrics && attestation != nil {
+		pipelineUploadSignatureVerifiedTotal.WithLabelValues("success").Inc()
+	}
+	return attestation, nil
+}
+
+// readOptionalFormFile reads a multipart part by key, returning nil (not an
+// error) when the part was not supplied.
+func readOptionalFormFile(r *http.Request, key string) []byte {
+	file, _, err := r.FormFile(key)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil
+	}
+	return content
+}
+
 func (s *PipelineUploadServer) canUploadVersionedPipeline(r *http.Request, pipelineId string, resourceAttributes *authorizationv1.ResourceAttributes) error {
 	if !common.IsMultiUserMode() {
 		// Skip authorization if not multi-user mode.
@@ -241,7 +810,7 @@ func (s *PipelineUploadServer) canUploadVersionedPipeline(r *http.Request, pipel
 			return util.Wrap(err, "Failed to authorize with the Pipeline ID.")
 		}
 		if len(resourceAttributes.Namespace) == 0 {
-		    resourceAttributes.Namespace = namespace
+			resourceAttributes.Namespace = namespace
 		}
 	}
 	if resourceAttributes.Namespace == "" {
@@ -278,7 +847,9 @@ func (s *PipelineUploadServer) writeErrorToResponse(w http.ResponseWriter, code
 }
 
 func NewPipelineUploadServer(resourceManager *resource.ResourceManager, options *PipelineUploadServerOptions) *PipelineUploadServer {
-	return &PipelineUploadServer{resourceManager: resourceManager, options: options}
+	s := &PipelineUploadServer{resourceManager: resourceManager, options: options}
+	s.startTusSweeper()
+	return s
 }
 
 func GetPipelineNamespace(queryString string) (string, error) {